@@ -0,0 +1,189 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+)
+
+// many_to_many关联的中间表操作
+type Association struct {
+	orm      *Orm
+	rel      *relation
+	ownerKey interface{}  //当前记录的主键值
+	related  *schemaField //关联表的主键
+	err      error
+}
+
+// 操作many_to_many关联的中间表，如Association(&user, "Roles").Append(&role)
+func (e *Orm) Association(owner interface{}, name string) *Association {
+	a := &Association{orm: e}
+
+	v := indirectValue(reflect.ValueOf(owner))
+	if v.Kind() != reflect.Struct {
+		a.err = e.setErrorInfo(errors.New("参数必须是结构体或结构体指针"))
+		return a
+	}
+
+	s := parseSchema(v.Type())
+	rel, ok := s.Relations[name]
+	if !ok || rel.Kind != "many_to_many" {
+		a.err = e.setErrorInfo(errors.New("未定义的many_to_many关联：" + name))
+		return a
+	}
+	if rel.Table == "" || rel.JoinTable == "" || rel.ForeignKey == "" || rel.References == "" {
+		a.err = e.setErrorInfo(errors.New("关联" + name + "必须设置table、join_table、foreign_key和references"))
+		return a
+	}
+
+	ownerField, err := e.relationKey(s, "", name)
+	if err != nil {
+		a.err = err
+		return a
+	}
+	if v.Field(ownerField.Index).IsZero() {
+		a.err = e.setErrorInfo(errors.New("关联" + name + "的主键值为空，请先保存记录"))
+		return a
+	}
+
+	related, err := e.relationKey(parseSchema(rel.ElemType), "", name)
+	if err != nil {
+		a.err = err
+		return a
+	}
+
+	a.rel = rel
+	a.ownerKey = v.Field(ownerField.Index).Interface()
+	a.related = related
+	return a
+}
+
+// 添加关联，关联记录主键为空时先插入关联表，已存在的中间表记录忽略
+func (a *Association) Append(values ...interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.orm.transaction(func() error {
+		return a.append(values)
+	})
+}
+
+// 替换关联，先清空再添加
+func (a *Association) Replace(values ...interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.orm.transaction(func() error {
+		if _, err := a.joinTable().Delete(); err != nil {
+			return err
+		}
+		return a.append(values)
+	})
+}
+
+// 删除关联，只删除中间表记录
+func (a *Association) Delete(values ...interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	keys, err := a.relatedKeys(values, false)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return a.orm.transaction(func() error {
+		_, err := a.joinTable().Where(a.rel.References, "in", keys).Delete()
+		return err
+	})
+}
+
+// 清空关联，只删除中间表记录
+func (a *Association) Clear() error {
+	if a.err != nil {
+		return a.err
+	}
+	return a.orm.transaction(func() error {
+		_, err := a.joinTable().Delete()
+		return err
+	})
+}
+
+// 关联个数
+func (a *Association) Count() (int64, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	return a.joinTable().Count()
+}
+
+// 当前记录在中间表中的查询构造器
+func (a *Association) joinTable() *Orm {
+	return a.orm.Session().Table(a.rel.JoinTable).Where(a.rel.ForeignKey, a.ownerKey)
+}
+
+// 插入中间表记录
+func (a *Association) append(values []interface{}) error {
+	keys, err := a.relatedKeys(values, true)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	//中间表没有对应的结构体，按外键字段动态生成
+	joinType := reflect.StructOf([]reflect.StructField{
+		{Name: "Owner", Type: reflect.TypeOf(a.ownerKey), Tag: reflect.StructTag(`sql:"` + a.rel.ForeignKey + `"`)},
+		{Name: "Related", Type: a.rel.ElemType.Field(a.related.Index).Type, Tag: reflect.StructTag(`sql:"` + a.rel.References + `"`)},
+	})
+	rows := reflect.MakeSlice(reflect.SliceOf(joinType), len(keys), len(keys))
+	for i, key := range keys {
+		rows.Index(i).Field(0).Set(reflect.ValueOf(a.ownerKey))
+		rows.Index(i).Field(1).Set(reflect.ValueOf(key))
+	}
+
+	_, err = a.orm.Session().Table(a.rel.JoinTable).InsertIgnore(rows.Interface())
+	return err
+}
+
+// 获取关联记录的主键值，支持结构体、结构体指针及其切片，insert为true时主键为空的记录先插入关联表
+func (a *Association) relatedKeys(values []interface{}, insert bool) ([]interface{}, error) {
+	var keys []interface{}
+	for _, value := range values {
+		v := indirectValue(reflect.ValueOf(value))
+		items := []reflect.Value{v}
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			items = items[:0]
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, indirectValue(v.Index(i)))
+			}
+		}
+
+		for _, item := range items {
+			if item.Kind() != reflect.Struct || item.Type() != a.rel.ElemType {
+				return nil, a.orm.setErrorInfo(errors.New("关联记录类型必须是" + a.rel.ElemType.String()))
+			}
+
+			key := item.Field(a.related.Index)
+			if key.IsZero() {
+				if !insert {
+					continue
+				}
+				if !item.CanAddr() {
+					return nil, a.orm.setErrorInfo(errors.New("新增关联记录请传指针，以便回写主键"))
+				}
+				if _, err := a.orm.Session().Table(a.rel.Table).Insert(item.Addr().Interface()); err != nil {
+					return nil, err
+				}
+
+				//主键不是自增字段时无法回写
+				if key.IsZero() {
+					return nil, a.orm.setErrorInfo(errors.New("新增关联记录后未获取到" + a.rel.ElemType.String() + "的主键，请设置主键或使用自增主键"))
+				}
+			}
+			keys = append(keys, key.Interface())
+		}
+	}
+	return keys, nil
+}
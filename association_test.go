@@ -0,0 +1,60 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+type assocRole struct {
+	Id   int64  `sql:"id,auto_increment"`
+	Name string `sql:"name"`
+}
+
+type assocTag struct {
+	Code string `sql:"code,pk"`
+	Name string `sql:"name"`
+}
+
+type assocUser struct {
+	Uid   int64        `sql:"uid,auto_increment"`
+	Roles []*assocRole `sql:",many_to_many,table=role,join_table=user_role,foreign_key=uid,references=role_id"`
+	Tags  []*assocTag  `sql:",many_to_many,table=tag,join_table=user_tag,foreign_key=uid,references=code"`
+}
+
+func TestAssociationAppendInsertsRelated(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(q fakeQuery) (driver.Result, error) { return fakeResult{id: 9, affected: 1}, nil }
+	e := newFakeOrm(t, c)
+
+	//主键为空的记录先插入，回写自增ID后写入中间表
+	role := &assocRole{Name: "admin"}
+	if err := e.Association(&assocUser{Uid: 1}, "Roles").Append(role); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if role.Id != 9 {
+		t.Fatalf("role.Id = %d, want 9", role.Id)
+	}
+	executed := c.executed()
+	if len(executed) != 2 || executed[0].query != "insert into `role` (`name`) values (?)" {
+		t.Fatalf("executed %v", executed)
+	}
+	if c.commits != 1 {
+		t.Fatalf("commits = %d, want 1", c.commits)
+	}
+}
+
+func TestAssociationAppendWithoutKey(t *testing.T) {
+	c := &fakeConnector{failAt: -1, affected: 1}
+	e := newFakeOrm(t, c)
+
+	//主键不是自增字段，插入后仍为空时报错并回滚
+	if err := e.Association(&assocUser{Uid: 1}, "Tags").Append(&assocTag{Name: "a"}); err == nil {
+		t.Fatal("Append() without key error = nil")
+	}
+	if n := len(c.executed()); n != 1 {
+		t.Fatalf("executed %d statements, want 1", n)
+	}
+	if c.commits != 0 || c.rollbacks != 1 {
+		t.Fatalf("commits, rollbacks = %d, %d, want 0, 1", c.commits, c.rollbacks)
+	}
+}
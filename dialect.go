@@ -0,0 +1,76 @@
+package orm
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 数据库方言，屏蔽不同数据库的语法差异
+type Dialect interface {
+	// 方言名称
+	Name() string
+
+	// 是否支持insert ... returning返回自增ID
+	SupportReturning() bool
+
+	// 第index个参数的占位符，从1开始，如mysql的?、postgres的$1
+	Placeholder(index int) string
+
+	// 表名、字段名加引号，如mysql的`user`.`uid`
+	Quote(identifier string) string
+
+	// 将参数转为sql字面量，用于生成调试用的完整sql
+	Literal(value interface{}) string
+
+	// 将驱动错误转为ErrDuplicateKey等哨兵错误，无对应时原样返回
+	TranslateError(err error) error
+}
+
+// mysql方言
+type MysqlDialect struct{}
+
+func (MysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (MysqlDialect) SupportReturning() bool {
+	return false
+}
+
+func (MysqlDialect) Placeholder(index int) string {
+	return "?"
+}
+
+func (MysqlDialect) Quote(identifier string) string {
+	return quoteIdentifier(identifier, "`")
+}
+
+func (MysqlDialect) Literal(value interface{}) string {
+	return mysqlLiteral(value)
+}
+
+func (MysqlDialect) TranslateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case 1062: //ER_DUP_ENTRY
+		return &driverError{kind: ErrDuplicateKey, err: err}
+	case 1216, 1217, 1451, 1452: //ER_NO_REFERENCED_ROW、ER_ROW_IS_REFERENCED
+		return &driverError{kind: ErrForeignKey, err: err}
+	case 1146: //ER_NO_SUCH_TABLE
+		return &driverError{kind: ErrNoTable, err: err}
+	}
+	return err
+}
+
+// 获取当前方言，未设置时默认为mysql
+func (e *Orm) getDialect() Dialect {
+	if e.Dialect == nil {
+		return MysqlDialect{}
+	}
+	return e.Dialect
+}
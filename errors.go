@@ -0,0 +1,52 @@
+package orm
+
+import (
+	"errors"
+	"strconv"
+)
+
+var (
+	// 查询单条时记录不存在
+	ErrRecordNotFound = errors.New("NOT FOUND")
+
+	// 唯一键冲突
+	ErrDuplicateKey = errors.New("唯一键冲突")
+
+	// 外键约束不满足
+	ErrForeignKey = errors.New("外键约束错误")
+
+	// 表不存在
+	ErrNoTable = errors.New("表不存在")
+
+	// 乐观锁更新时版本号不一致，记录已被其他请求修改
+	ErrStaleObject = errors.New("记录已被修改，版本号不一致")
+)
+
+// 带调用位置的错误，可通过errors.Is/errors.As判断原始错误
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return "File: " + e.File + ":" + strconv.Itoa(e.Line) + ", " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// 驱动错误对应的哨兵错误，同时保留原始驱动错误
+type driverError struct {
+	kind error
+	err  error
+}
+
+func (e *driverError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *driverError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
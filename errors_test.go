@@ -0,0 +1,79 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestErrRecordNotFound(t *testing.T) {
+	c := &fakeConnector{columns: []string{"uid", "name"}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//记录调用位置，保留哨兵错误
+	var user fakeUser
+	err := e.Table("user").Where("uid", 1).FindOne(&user)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("FindOne() error = %v, want %v", err, ErrRecordNotFound)
+	}
+	var wrapped *Error
+	if !errors.As(err, &wrapped) || !strings.HasSuffix(wrapped.File, ".go") || wrapped.Line == 0 {
+		t.Fatalf("FindOne() error = %#v, want *Error with caller", err)
+	}
+	assertLastQuery(t, c, "select * from `user` where (`uid`=?)  limit 1", int64(1))
+
+	e.DisableCaller = true
+	if err := e.Table("user").FindOne(&user); err != ErrRecordNotFound {
+		t.Fatalf("FindOne() error = %#v, want %v without caller", err, ErrRecordNotFound)
+	}
+}
+
+func TestDriverErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		number uint16
+		want   error
+	}{
+		{"duplicate key", 1062, ErrDuplicateKey},
+		{"foreign key", 1452, ErrForeignKey},
+		{"row is referenced", 1451, ErrForeignKey},
+		{"no table", 1146, ErrNoTable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driverErr := &mysql.MySQLError{Number: tt.number, Message: tt.name}
+			c := &fakeConnector{failAt: -1}
+			c.exec = func(fakeQuery) (driver.Result, error) { return nil, driverErr }
+			e := newFakeOrm(t, c)
+
+			_, err := e.Table("user").Insert(&fakeUser{Uid: 1})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Insert() error = %v, want %v", err, tt.want)
+			}
+
+			//保留原始驱动错误
+			var got *mysql.MySQLError
+			if !errors.As(err, &got) || got.Number != tt.number {
+				t.Fatalf("Insert() error = %v, want driver error %d", err, tt.number)
+			}
+		})
+	}
+
+	//其他错误原样返回
+	driverErr := &mysql.MySQLError{Number: 1064, Message: "syntax"}
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) { return nil, driverErr }
+	e := newFakeOrm(t, c)
+	_, err := e.Exec("select")
+	if !errors.Is(err, driverErr) {
+		t.Fatalf("Exec() error = %v, want %v", err, driverErr)
+	}
+	for _, sentinel := range []error{ErrDuplicateKey, ErrForeignKey, ErrNoTable, ErrRecordNotFound} {
+		if errors.Is(err, sentinel) {
+			t.Fatalf("Exec() error = %v, want not %v", err, sentinel)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package orm
+
+import "reflect"
+
+// 模型钩子，模型实现对应方法即可，Before钩子返回错误时终止操作
+// 钩子参数为共享连接和事务的新查询构造器，可在钩子中执行查询
+type BeforeInsertHook interface {
+	BeforeInsert(*Orm) error
+}
+
+type AfterInsertHook interface {
+	AfterInsert(*Orm) error
+}
+
+type BeforeUpdateHook interface {
+	BeforeUpdate(*Orm) error
+}
+
+type AfterUpdateHook interface {
+	AfterUpdate(*Orm) error
+}
+
+type BeforeDeleteHook interface {
+	BeforeDelete(*Orm) error
+}
+
+type AfterDeleteHook interface {
+	AfterDelete(*Orm) error
+}
+
+type AfterFindHook interface {
+	AfterFind(*Orm) error
+}
+
+// 调用模型的钩子方法，可寻址时传指针，以支持指针接收者
+func (e *Orm) callHook(item reflect.Value, hook func(model interface{}, e *Orm) error) error {
+	if !item.IsValid() {
+		return nil
+	}
+	if item.CanAddr() {
+		return hook(item.Addr().Interface(), e)
+	}
+	return hook(item.Interface(), e)
+}
+
+func beforeInsert(model interface{}, e *Orm) error {
+	if h, ok := model.(BeforeInsertHook); ok {
+		return h.BeforeInsert(e.Session())
+	}
+	return nil
+}
+
+func afterInsert(model interface{}, e *Orm) error {
+	if h, ok := model.(AfterInsertHook); ok {
+		return h.AfterInsert(e.Session())
+	}
+	return nil
+}
+
+func beforeUpdate(model interface{}, e *Orm) error {
+	if h, ok := model.(BeforeUpdateHook); ok {
+		return h.BeforeUpdate(e.Session())
+	}
+	return nil
+}
+
+func afterUpdate(model interface{}, e *Orm) error {
+	if h, ok := model.(AfterUpdateHook); ok {
+		return h.AfterUpdate(e.Session())
+	}
+	return nil
+}
+
+func beforeDelete(model interface{}, e *Orm) error {
+	if h, ok := model.(BeforeDeleteHook); ok {
+		return h.BeforeDelete(e.Session())
+	}
+	return nil
+}
+
+func afterDelete(model interface{}, e *Orm) error {
+	if h, ok := model.(AfterDeleteHook); ok {
+		return h.AfterDelete(e.Session())
+	}
+	return nil
+}
+
+func afterFind(model interface{}, e *Orm) error {
+	if h, ok := model.(AfterFindHook); ok {
+		return h.AfterFind(e.Session())
+	}
+	return nil
+}
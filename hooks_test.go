@@ -0,0 +1,153 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 钩子的调用记录
+var hookCalls []string
+
+type hookUser struct {
+	Uid  int64  `sql:"uid,auto_increment"`
+	Name string `sql:"name"`
+}
+
+var errHookInvalid = errors.New("name is empty")
+
+func (u *hookUser) BeforeInsert(*Orm) error {
+	if u.Name == "" {
+		return errHookInvalid
+	}
+	u.Name = strings.ToLower(u.Name)
+	hookCalls = append(hookCalls, "BeforeInsert")
+	return nil
+}
+
+func (u *hookUser) AfterInsert(*Orm) error {
+	hookCalls = append(hookCalls, fmt.Sprintf("AfterInsert %d", u.Uid))
+	return nil
+}
+
+func (u *hookUser) BeforeUpdate(*Orm) error {
+	if u.Name == "" {
+		return errHookInvalid
+	}
+	u.Name = strings.ToLower(u.Name)
+	hookCalls = append(hookCalls, "BeforeUpdate")
+	return nil
+}
+
+func (u *hookUser) AfterUpdate(*Orm) error {
+	hookCalls = append(hookCalls, "AfterUpdate")
+	return nil
+}
+
+func (u *hookUser) BeforeDelete(*Orm) error {
+	if u.Uid == 0 {
+		return errHookInvalid
+	}
+	hookCalls = append(hookCalls, "BeforeDelete")
+	return nil
+}
+
+func (u *hookUser) AfterDelete(*Orm) error {
+	hookCalls = append(hookCalls, "AfterDelete")
+	return nil
+}
+
+func (u *hookUser) AfterFind(*Orm) error {
+	u.Name += "!"
+	hookCalls = append(hookCalls, "AfterFind")
+	return nil
+}
+
+func assertHookCalls(t *testing.T, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(hookCalls, want) {
+		t.Fatalf("hook calls = %v, want %v", hookCalls, want)
+	}
+	hookCalls = nil
+}
+
+func TestInsertHooks(t *testing.T) {
+	hookCalls = nil
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) { return fakeResult{id: 5, affected: 1}, nil }
+	e := newFakeOrm(t, c)
+
+	//插入前修改的值写入sql，插入后可以取到自增ID
+	user := hookUser{Name: "A"}
+	if _, err := e.Table("user").Insert(&user); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`) values (?)", "a")
+	assertHookCalls(t, "BeforeInsert", "AfterInsert 5")
+
+	//插入前钩子返回错误时终止
+	if _, err := e.Table("user").Insert([]*hookUser{{Name: "b"}, {}}); !errors.Is(err, errHookInvalid) {
+		t.Fatalf("Insert() error = %v, want %v", err, errHookInvalid)
+	}
+	if n := len(c.executed()); n != 1 {
+		t.Fatalf("executed %d statements, want 1", n)
+	}
+	assertHookCalls(t, "BeforeInsert")
+}
+
+func TestUpdateDeleteHooks(t *testing.T) {
+	hookCalls = nil
+	c := &fakeConnector{failAt: -1, affected: 1}
+	e := newFakeOrm(t, c)
+
+	if _, err := e.Table("user").UpdateByPK(&hookUser{Uid: 1, Name: "B"}); err != nil {
+		t.Fatalf("UpdateByPK() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=? where (`uid`=?)", "b", int64(1))
+	assertHookCalls(t, "BeforeUpdate", "AfterUpdate")
+
+	if _, err := e.Table("user").Where("uid", 1).Update(&hookUser{}); !errors.Is(err, errHookInvalid) {
+		t.Fatalf("Update() error = %v, want %v", err, errHookInvalid)
+	}
+
+	if _, err := e.Table("user").DeleteByPK(&hookUser{Uid: 1}); err != nil {
+		t.Fatalf("DeleteByPK() error = %v", err)
+	}
+	assertLastQuery(t, c, "delete from `user` where (`uid`=?)", int64(1))
+	assertHookCalls(t, "BeforeDelete", "AfterDelete")
+
+	before := len(c.executed())
+	if _, err := e.Table("user").Model(&hookUser{}).Where("name", "x").Delete(); !errors.Is(err, errHookInvalid) {
+		t.Fatalf("Delete() error = %v, want %v", err, errHookInvalid)
+	}
+	if len(c.executed()) != before {
+		t.Fatal("aborted Update/Delete executed")
+	}
+	assertHookCalls(t)
+}
+
+func TestFindHooks(t *testing.T) {
+	hookCalls = nil
+	c := &fakeConnector{columns: []string{"uid", "name"}, data: [][]string{{"1", "a"}, {"2", "b"}}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	var users []hookUser
+	if err := e.Table("user").Find(&users); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if users[0].Name != "a!" || users[1].Name != "b!" {
+		t.Fatalf("users = %+v", users)
+	}
+	assertHookCalls(t, "AfterFind", "AfterFind")
+
+	c.data = c.data[:1]
+	var user hookUser
+	if err := e.Table("user").FindOne(&user); err != nil || user.Name != "a!" {
+		t.Fatalf("FindOne() = %+v, %v", user, err)
+	}
+	assertLastQuery(t, c, "select * from `user` limit 1")
+	assertHookCalls(t, "AfterFind")
+}
@@ -0,0 +1,79 @@
+package orm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// 原样拼接到sql中的表达式，不校验也不加引号，如Field(orm.Raw("count(*) as num"))，注意不要拼接用户输入
+type Raw string
+
+// 合法的标识符，字母或下划线开头，最多带一级前缀，如uid、user.uid
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// where/having支持的操作符
+var allowedOperators = map[string]bool{
+	"=": true, "!=": true, "<>": true, ">": true, ">=": true, "<": true, "<=": true,
+	"like": true, "not like": true, "in": true, "not in": true, "is": true, "is not": true,
+	"between": true, "not between": true, "regexp": true, "not regexp": true,
+}
+
+// 校验标识符并加引号，设置模型后字段名只允许模型中定义的字段
+func (e *Orm) quoteColumn(column interface{}) (string, error) {
+	switch c := column.(type) {
+	case Raw:
+		return string(c), nil
+	case string:
+		if !identifierPattern.MatchString(c) {
+			return "", errors.New("非法的字段名：" + c + "，表达式请使用orm.Raw")
+		}
+		if e.ModelType != nil {
+			name := c[strings.LastIndex(c, ".")+1:]
+			if parseSchema(e.ModelType).fieldByColumn(name) == nil {
+				return "", errors.New("模型" + e.ModelType.Name() + "中没有字段：" + c)
+			}
+		}
+		return e.getDialect().Quote(c), nil
+	}
+	return "", errors.New("字段名必须是字符串或orm.Raw")
+}
+
+// 查询字段，支持*和user.*
+func (e *Orm) quoteField(field interface{}) (string, error) {
+	if s, ok := field.(string); ok {
+		if s == "*" {
+			return s, nil
+		}
+		if prefix, found := strings.CutSuffix(s, ".*"); found {
+			if !identifierPattern.MatchString(prefix) {
+				return "", errors.New("非法的字段名：" + s + "，表达式请使用orm.Raw")
+			}
+			return e.getDialect().Quote(prefix) + ".*", nil
+		}
+	}
+	return e.quoteColumn(field)
+}
+
+// 表名加引号，支持db.table
+func (e *Orm) quoteTable() string {
+	return e.getDialect().Quote(e.GetTable())
+}
+
+// 校验操作符，返回小写形式
+func checkOperator(operator string) (string, error) {
+	op := strings.Join(strings.Fields(strings.ToLower(operator)), " ")
+	if !allowedOperators[op] {
+		return "", errors.New("不支持的操作符：" + operator)
+	}
+	return op, nil
+}
+
+// mysql使用反引号，每一级分别加引号
+func quoteIdentifier(name string, quote string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,136 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 语句类型
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpSelect = "select"
+	OpExec   = "exec"  //原生Exec
+	OpQuery  = "query" //原生Query
+)
+
+// 拦截器处理的语句，拦截器可以修改SQL和Args
+type Statement struct {
+	Op     string        //语句类型
+	Table  string        //表名，原生sql为空
+	SQL    string        //带占位符的sql
+	Args   []interface{} //绑定的参数
+	Result sql.Result    //增删改的执行结果
+	Rows   *sql.Rows     //查询的结果
+}
+
+// 执行语句，执行结果写入Statement
+type Handler func(stmt *Statement) error
+
+// 拦截器，在next前后加入处理逻辑；不调用next即为短路，增删改可直接设置Result，查询只能返回错误
+type Interceptor interface {
+	Intercept(stmt *Statement, next Handler) error
+}
+
+// 函数形式的拦截器
+type InterceptorFunc func(stmt *Statement, next Handler) error
+
+func (f InterceptorFunc) Intercept(stmt *Statement, next Handler) error {
+	return f(stmt, next)
+}
+
+// 注册拦截器，先注册的在最外层
+func (e *Orm) Use(interceptors ...Interceptor) *Orm {
+	e.Interceptors = append(e.Interceptors, interceptors...)
+	return e
+}
+
+// 依次经过拦截器后执行handler
+func (e *Orm) intercept(stmt *Statement, handler Handler) error {
+	for i := len(e.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := e.Interceptors[i], handler
+		handler = func(stmt *Statement) error {
+			return interceptor.Intercept(stmt, next)
+		}
+	}
+	return handler(stmt)
+}
+
+// 执行增删改，构造器的语句使用预处理语句（见StmtCache），原生sql和DisablePrepare时直接Exec
+func (e *Orm) execStatement(op string, query string, args []interface{}) (sql.Result, error) {
+	stmt := &Statement{Op: op, SQL: query, Args: args}
+	if op != OpExec {
+		stmt.Table = e.GetTable()
+	}
+
+	start := time.Now()
+	err := e.intercept(stmt, func(stmt *Statement) error {
+		var err error
+		if stmt.Op == OpExec {
+			stmt.Result, err = e.primaryExecutor().ExecContext(e.context(), stmt.SQL, stmt.Args...)
+		} else if e.DisablePrepare {
+			stmt.Result, err = e.getExecutor().ExecContext(e.context(), stmt.SQL, stmt.Args...)
+		} else {
+			stmt.Result, err = e.execPrepared(stmt.SQL, stmt.Args)
+		}
+		return err
+	})
+
+	//记录拦截器修改后的语句
+	e.Prepare, e.AllExec = stmt.SQL, stmt.Args
+	e.generateSql()
+	if err != nil {
+		e.logStatement(stmt, start, 0, 0, err)
+		return nil, err
+	}
+
+	//拦截器短路且未设置结果
+	if stmt.Result == nil {
+		stmt.Result = execResult{}
+	}
+
+	rowsAffected, _ := stmt.Result.RowsAffected()
+	e.logStatement(stmt, start, rowsAffected, 0, nil)
+	return stmt.Result, nil
+}
+
+// 执行查询，scan读取结果并返回读取的行数，rows由此处关闭
+func (e *Orm) queryStatement(op string, query string, args []interface{}, scan func(rows *sql.Rows) (int64, error)) error {
+	stmt := &Statement{Op: op, SQL: query, Args: args}
+	if op != OpQuery {
+		stmt.Table = e.GetTable()
+	}
+
+	start := time.Now()
+	err := e.intercept(stmt, func(stmt *Statement) error {
+		executor, replica := e.getReader(stmt.Op)
+		queryStart := time.Now()
+		rows, err := executor.QueryContext(e.context(), stmt.SQL, stmt.Args...)
+		if err == nil {
+			e.observeReplica(replica, time.Since(queryStart))
+		}
+		stmt.Rows = rows
+		return err
+	})
+
+	//记录拦截器修改后的语句
+	e.Prepare, e.AllExec = stmt.SQL, stmt.Args
+	e.generateSql()
+	if err == nil && stmt.Rows == nil {
+		err = errors.New("拦截器未返回查询结果")
+	}
+	if err != nil {
+		e.logStatement(stmt, start, 0, 0, err)
+		return err
+	}
+	defer stmt.Rows.Close()
+
+	rowsReturned, err := scan(stmt.Rows)
+	if err == nil {
+		err = stmt.Rows.Err()
+	}
+	e.logStatement(stmt, start, 0, rowsReturned, err)
+	return err
+}
@@ -0,0 +1,129 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInterceptorStatements(t *testing.T) {
+	c := &fakeConnector{columns: []string{"uid", "name"}, data: [][]string{{"1", "a"}}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	var got []Statement
+	e.Use(InterceptorFunc(func(stmt *Statement, next Handler) error {
+		got = append(got, Statement{Op: stmt.Op, Table: stmt.Table, SQL: stmt.SQL, Args: stmt.Args})
+		return next(stmt)
+	}))
+
+	if _, err := e.Table("user").Insert(&fakeUser{Uid: 1, Name: "a"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "b"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := e.Table("user").Where("uid", 1).Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := e.Table("user").Where("uid", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if _, err := e.Exec("delete from user where uid=?", 2); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if _, err := e.Query("select * from user where uid=?", 3); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	want := []Statement{
+		{Op: OpInsert, Table: "user", SQL: "insert into `user` (`uid`,`name`) values (?,?)", Args: []interface{}{int64(1), "a"}},
+		{Op: OpUpdate, Table: "user", SQL: "update `user` set `name`=? where (`uid`=?) ", Args: []interface{}{"b", 1}},
+		{Op: OpDelete, Table: "user", SQL: "delete from `user` where (`uid`=?) ", Args: []interface{}{1}},
+		{Op: OpSelect, Table: "user", SQL: "select * from `user` where (`uid`=?) ", Args: []interface{}{1}},
+		{Op: OpExec, SQL: "delete from user where uid=?", Args: []interface{}{2}},
+		{Op: OpQuery, SQL: "select * from user where uid=?", Args: []interface{}{3}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("intercepted %#v, want %#v", got, want)
+	}
+	if n := len(c.executed()); n != len(want) {
+		t.Fatalf("executed %d statements, want %d", n, len(want))
+	}
+}
+
+func TestInterceptorModify(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//先注册的在最外层
+	var order []string
+	e.Use(InterceptorFunc(func(stmt *Statement, next Handler) error {
+		order = append(order, "outer")
+		stmt.SQL += " /* outer */"
+		return next(stmt)
+	}), InterceptorFunc(func(stmt *Statement, next Handler) error {
+		order = append(order, "inner")
+		stmt.Args = append(stmt.Args, 9)
+		return next(stmt)
+	}))
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=? where (`uid`=?)  /* outer */", "a", int64(1), int64(9))
+	if !reflect.DeepEqual(order, []string{"outer", "inner"}) {
+		t.Fatalf("order = %v", order)
+	}
+	if sql := e.GetLastSql(); sql != "update `user` set `name`='a' where (`uid`=1)  /* outer */" {
+		t.Fatalf("GetLastSql() = %s", sql)
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//增删改直接返回结果，不执行
+	e.Use(InterceptorFunc(func(stmt *Statement, next Handler) error {
+		if stmt.Op == OpSelect {
+			return next(stmt)
+		}
+		stmt.Result = fakeResult{id: 7, affected: 3}
+		return nil
+	}))
+	if n, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil || n != 3 {
+		t.Fatalf("Update() = %d, %v, want 3", n, err)
+	}
+	if id, err := e.Table("user").Insert(&fakeUser{Name: "a"}); err != nil || id != 7 {
+		t.Fatalf("Insert() = %d, %v, want 7", id, err)
+	}
+	assertNoQuery(t, c)
+
+	//查询短路时必须返回错误或结果
+	e.Interceptors = nil
+	e.Use(InterceptorFunc(func(stmt *Statement, next Handler) error { return nil }))
+	if _, err := e.Table("user").Select(); err == nil {
+		t.Fatal("Select() without rows error = nil")
+	}
+	assertNoQuery(t, c)
+}
+
+func TestInterceptorError(t *testing.T) {
+	errDriver := errors.New("fake: exec failed")
+	errWrapped := errors.New("wrapped")
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) { return nil, errDriver }
+	e := newFakeOrm(t, c)
+
+	e.Use(InterceptorFunc(func(stmt *Statement, next Handler) error {
+		if err := next(stmt); err != nil {
+			return errors.Join(errWrapped, err)
+		}
+		return nil
+	}))
+	_, err := e.Table("user").Where("uid", 1).Update("name", "a")
+	if !errors.Is(err, errWrapped) || !errors.Is(err, errDriver) {
+		t.Fatalf("Update() error = %v, want wrapped driver error", err)
+	}
+}
@@ -0,0 +1,145 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 将参数代入占位符，生成完整的sql，仅用于日志和调试，执行仍使用占位符
+// 引号内的?不是占位符，参数个数不足时保留剩余的?
+func interpolate(d Dialect, query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+
+	var buf strings.Builder
+	buf.Grow(len(query) + len(args)*8)
+
+	argIndex := 0
+	var quote rune
+	escaped := false
+	for _, c := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			//引号内，反斜杠转义下一个字符，遇到相同引号结束（成对的引号转义不影响结果）
+			if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && argIndex < len(args):
+			buf.WriteString(d.Literal(args[argIndex]))
+			argIndex++
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
+
+// 生成mysql字面量
+func mysqlLiteral(value interface{}) string {
+	//先转为驱动支持的值
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return "'" + mysqlEscape(err.Error()) + "'"
+		}
+		value = v
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		//非utf8的字符串与二进制一样使用十六进制，避免转义时替换为U+FFFD
+		if !utf8.ValidString(v) {
+			return "X'" + hex.EncodeToString([]byte(v)) + "'"
+		}
+		return "'" + mysqlEscape(v) + "'"
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		if utf8.Valid(v) {
+			return "'" + mysqlEscape(string(v)) + "'"
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		if v.IsZero() {
+			return "'0000-00-00 00:00:00'"
+		}
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	}
+
+	//自定义类型及指针按底层类型处理
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return mysqlLiteral(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return mysqlLiteral(rv.Bool())
+	case reflect.String:
+		return mysqlLiteral(rv.String())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return mysqlLiteral(rv.Bytes())
+		}
+	}
+
+	//其他类型转为字符串
+	return "'" + mysqlEscape(fmt.Sprint(value)) + "'"
+}
+
+// mysql字符串转义，与NO_BACKSLASH_ESCAPES关闭时的规则一致
+func mysqlEscape(s string) string {
+	var buf strings.Builder
+	buf.Grow(len(s))
+	for _, c := range s {
+		switch c {
+		case 0:
+			buf.WriteString(`\0`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\'':
+			buf.WriteString(`\'`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\x1a':
+			buf.WriteString(`\Z`)
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	return buf.String()
+}
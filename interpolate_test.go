@@ -0,0 +1,91 @@
+package orm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+type literalStatus int
+
+type literalValuer struct {
+	value driver.Value
+	err   error
+}
+
+func (v literalValuer) Value() (driver.Value, error) { return v.value, v.err }
+
+func TestMysqlLiteral(t *testing.T) {
+	var nilInt *int
+	var nilTime *time.Time
+	n := 7
+	at := time.Date(2024, 5, 6, 7, 8, 9, 120000000, time.UTC)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, "NULL"},
+		{"typed nil pointer", nilInt, "NULL"},
+		{"typed nil time pointer", nilTime, "NULL"},
+		{"nil bytes", []byte(nil), "NULL"},
+		{"pointer", &n, "7"},
+		{"int", -12, "-12"},
+		{"uint", uint64(18446744073709551615), "18446744073709551615"},
+		{"custom int", literalStatus(3), "3"},
+		{"float64", 1.5, "1.5"},
+		{"float64 exponent", 1e21, "1e+21"},
+		{"float32", float32(0.1), "0.1"},
+		{"bool", true, "1"},
+		{"string", "abc", "'abc'"},
+		{"escaped quotes", `it's "x"`, `'it\'s \"x\"'`},
+		{"backslash and control", "a\\b\n\r\x00\x1a", `'a\\b\n\r\0\Z'`},
+		{"utf8 bytes", []byte("中文"), "'中文'"},
+		{"invalid utf8 bytes", []byte{0xff, 0x00, 'a'}, "X'ff0061'"},
+		{"invalid utf8 string", string([]byte{0xfe, 'b'}), "X'fe62'"},
+		{"time", at, "'2024-05-06 07:08:09.12'"},
+		{"zero time", time.Time{}, "'0000-00-00 00:00:00'"},
+		{"valuer", sql.NullString{String: "x'y", Valid: true}, `'x\'y'`},
+		{"null valuer", sql.NullInt64{}, "NULL"},
+		{"valuer returning time", literalValuer{value: at}, "'2024-05-06 07:08:09.12'"},
+		{"valuer error", literalValuer{err: errors.New("bad'value")}, `'bad\'value'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mysqlLiteral(tt.value); got != tt.want {
+				t.Fatalf("mysqlLiteral(%#v) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		want  string
+	}{
+		{"no args", "select 1 where a=?", nil, "select 1 where a=?"},
+		{"args", "select * from t where a=? and b=?", []interface{}{1, "x"}, "select * from t where a=1 and b='x'"},
+		{"question mark in single quotes", "select '?' ,a from t where a=?", []interface{}{1}, "select '?' ,a from t where a=1"},
+		{"question mark in double quotes", `select "?" where a=?`, []interface{}{1}, `select "?" where a=1`},
+		{"question mark in backticks", "select `a?` from t where a=?", []interface{}{1}, "select `a?` from t where a=1"},
+		{"escaped quote", `select 'it\'s ?' where a=?`, []interface{}{1}, `select 'it\'s ?' where a=1`},
+		{"doubled quote", `select 'it''s ?' where a=?`, []interface{}{1}, `select 'it''s ?' where a=1`},
+		{"value contains question mark", "where a=? and b=?", []interface{}{"?", 2}, "where a='?' and b=2"},
+		{"value contains quote", "where a=? and b=?", []interface{}{"'", 2}, `where a='\'' and b=2`},
+		{"nil", "where a=?", []interface{}{nil}, "where a=NULL"},
+		{"too few args", "where a=? and b=?", []interface{}{1}, "where a=1 and b=?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interpolate(MysqlDialect{}, tt.query, tt.args); got != tt.want {
+				t.Fatalf("interpolate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package orm
+
+import "errors"
+
+// 加排他锁，select ... for update，需在事务中使用
+func (e *Orm) LockForUpdate() *Orm {
+	e.LockParam = "for update"
+	return e
+}
+
+// 加共享锁，select ... for share，需在事务中使用
+func (e *Orm) LockForShare() *Orm {
+	e.LockParam = "for share"
+	return e
+}
+
+// 跳过已被锁定的行，需与LockForUpdate/LockForShare一起使用
+func (e *Orm) SkipLocked() *Orm {
+	e.LockOption = "skip locked"
+	return e
+}
+
+// 行已被锁定时立即返回错误，需与LockForUpdate/LockForShare一起使用
+func (e *Orm) NoWait() *Orm {
+	e.LockOption = "nowait"
+	return e
+}
+
+// 拼接锁语句
+func (e *Orm) buildLock() string {
+	if e.LockParam == "" {
+		return ""
+	}
+	if e.LockOption != "" {
+		return " " + e.LockParam + " " + e.LockOption
+	}
+	return " " + e.LockParam
+}
+
+// 检查加锁的使用方式
+func (e *Orm) checkLock() error {
+	if e.LockOption != "" && e.LockParam == "" {
+		return e.setErrorInfo(errors.New("SkipLocked/NoWait需与LockForUpdate或LockForShare一起使用"))
+	}
+	if e.LockParam != "" && e.TransStatus != 1 {
+		return e.setErrorInfo(errors.New("LockForUpdate/LockForShare必须在事务中使用"))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// 日志级别
+type LogLevel int
+
+const (
+	LogInfo  LogLevel = iota //正常执行
+	LogWarn                  //慢查询
+	LogError                 //执行出错
+)
+
+// 一条语句的执行记录
+type LogEntry struct {
+	Level        LogLevel
+	Op           string        //语句类型
+	Table        string        //表名，原生sql为空
+	SQL          string        //带占位符的sql
+	Args         []interface{} //绑定的参数
+	Duration     time.Duration //耗时，查询包含读取结果的时间
+	RowsAffected int64         //增删改影响的行数
+	RowsReturned int64         //查询返回的行数
+	Slow         bool          //超过慢查询阈值
+	Err          error
+}
+
+// 日志接口，记录每条执行的语句
+type Logger interface {
+	Log(entry *LogEntry)
+}
+
+// 设置日志，slowThreshold大于0时，耗时超过该值的语句以LogWarn级别记录
+func (e *Orm) SetLogger(logger Logger, slowThreshold time.Duration) *Orm {
+	e.Logger = logger
+	e.SlowThreshold = slowThreshold
+	return e
+}
+
+// 记录执行的语句
+func (e *Orm) logStatement(stmt *Statement, start time.Time, rowsAffected, rowsReturned int64, err error) {
+	if e.Logger == nil {
+		return
+	}
+
+	entry := &LogEntry{
+		Level:        LogInfo,
+		Op:           stmt.Op,
+		Table:        stmt.Table,
+		SQL:          stmt.SQL,
+		Args:         stmt.Args,
+		Duration:     time.Since(start),
+		RowsAffected: rowsAffected,
+		RowsReturned: rowsReturned,
+		Err:          err,
+	}
+	if e.SlowThreshold > 0 && entry.Duration >= e.SlowThreshold {
+		entry.Slow = true
+		entry.Level = LogWarn
+	}
+	if err != nil {
+		entry.Level = LogError
+	}
+	e.Logger.Log(entry)
+}
+
+// log/slog适配
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+// 新建slog日志，logger为空时使用slog.Default()
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{Logger: logger}
+}
+
+func (l *SlogLogger) Log(entry *LogEntry) {
+	level := slog.LevelInfo
+	msg := "sql"
+	switch entry.Level {
+	case LogWarn:
+		level = slog.LevelWarn
+		msg = "slow sql"
+	case LogError:
+		level = slog.LevelError
+		msg = "sql error"
+	}
+
+	attrs := []slog.Attr{
+		slog.String("op", entry.Op),
+		slog.String("table", entry.Table),
+		slog.String("sql", entry.SQL),
+		slog.Any("args", entry.Args),
+		slog.Duration("duration", entry.Duration),
+		slog.Int64("rows_affected", entry.RowsAffected),
+		slog.Int64("rows_returned", entry.RowsReturned),
+	}
+	if entry.Err != nil {
+		attrs = append(attrs, slog.String("error", entry.Err.Error()))
+	}
+	l.Logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
@@ -0,0 +1,106 @@
+package orm
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录所有日志
+type recordLogger struct {
+	entries []*LogEntry
+}
+
+func (l *recordLogger) Log(entry *LogEntry) {
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordLogger) last(t *testing.T) *LogEntry {
+	t.Helper()
+	if len(l.entries) == 0 {
+		t.Fatal("no log entry")
+	}
+	return l.entries[len(l.entries)-1]
+}
+
+func TestLogger(t *testing.T) {
+	c := &fakeConnector{columns: []string{"uid", "name"}, data: [][]string{{"1", "a"}, {"2", "b"}}, failAt: -1, affected: 2}
+	logger := &recordLogger{}
+	e := newFakeOrm(t, c).SetLogger(logger, 0)
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	entry := logger.last(t)
+	if entry.Level != LogInfo || entry.Op != OpUpdate || entry.Table != "user" || entry.RowsAffected != 2 || entry.Slow {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if entry.SQL != "update `user` set `name`=? where (`uid`=?) " || !reflect.DeepEqual(entry.Args, []interface{}{"a", 1}) {
+		t.Fatalf("entry = %q %v", entry.SQL, entry.Args)
+	}
+	if sql := e.GetLastSql(); sql != "update `user` set `name`='a' where (`uid`=1) " {
+		t.Fatalf("GetLastSql() = %s", sql)
+	}
+
+	var users []fakeUser
+	if err := e.Table("user").Find(&users); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if entry := logger.last(t); entry.Op != OpSelect || entry.RowsReturned != 2 || entry.SQL != "select * from `user`" {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if sql := e.GetLastSql(); sql != "select * from `user`" {
+		t.Fatalf("GetLastSql() = %s", sql)
+	}
+
+	if _, err := e.Query("select * from user where uid=?", 1); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if entry := logger.last(t); entry.Op != OpQuery || entry.Table != "" || entry.RowsReturned != 2 {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if n := len(logger.entries); n != 3 {
+		t.Fatalf("logged %d entries, want 3", n)
+	}
+}
+
+func TestLoggerError(t *testing.T) {
+	errDriver := errors.New("fake: exec failed")
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) { return nil, errDriver }
+	logger := &recordLogger{}
+	e := newFakeOrm(t, c).SetLogger(logger, 0)
+
+	if _, err := e.Exec("delete from user where uid=?", 1); !errors.Is(err, errDriver) {
+		t.Fatalf("Exec() error = %v, want %v", err, errDriver)
+	}
+	if entry := logger.last(t); entry.Level != LogError || !errors.Is(entry.Err, errDriver) || entry.Op != OpExec {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
+
+func TestLoggerSlow(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) {
+		time.Sleep(2 * time.Millisecond)
+		return driver.RowsAffected(1), nil
+	}
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	e := newFakeOrm(t, c).SetLogger(logger, time.Millisecond)
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"level=WARN", `msg="slow sql"`, "op=update", "table=user", "rows_affected=1"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log = %s, want %s", out, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 原生sql的条件，如WhereRaw("uid > ? or status = ?", 10, 1)，也支持命名参数WhereRaw("uid > :uid", map[string]any{"uid": 10})，与其他条件为and关系
+func (e *Orm) WhereRaw(query string, args ...interface{}) *Orm {
+	query, args, err := e.bindNamed(query, args)
+	if err != nil {
+		return e.addError(err)
+	}
+	e.appendWhere("and", query)
+	e.WhereExec = append(e.WhereExec, args...)
+	return e
+}
+
+// 命名参数：args只有一个map[string]any或结构体时，将:name、@name替换为当前方言的占位符，并按出现顺序生成参数
+// 结构体按sql tag中的字段名取值，切片参数展开为多个占位符，便于in (:ids)
+func (e *Orm) bindNamed(query string, args []interface{}) (string, []interface{}, error) {
+	if len(args) != 1 {
+		return query, args, nil
+	}
+	lookup := namedLookup(args[0])
+	if lookup == nil {
+		return query, args, nil
+	}
+
+	d := e.getDialect()
+	var buf strings.Builder
+	var bound []interface{}
+
+	var quote byte
+	var comment byte //'\n'为单行注释，'*'为多行注释
+	escaped := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			//引号内不是参数
+			if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case comment != 0:
+			//注释内不是参数
+			if comment == '\n' && c == '\n' {
+				comment = 0
+			} else if comment == '*' && c == '*' && i+1 < len(query) && query[i+1] == '/' {
+				buf.WriteString("*/")
+				i++
+				comment = 0
+				continue
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && isLineComment(query[i:])):
+			comment = '\n'
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			buf.WriteString("/*")
+			i++
+			comment = '*'
+			continue
+		case (c == ':' || c == '@') && i+1 < len(query) && isNameStart(query[i+1]) && (i == 0 || (query[i-1] != c && query[i-1] != ':')):
+			//::类型转换、@@系统变量、:=赋值不是参数
+			j := i + 1
+			for j < len(query) && isNamePart(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			value, ok := lookup(name)
+			if !ok {
+				return "", nil, errors.New("命名参数没有对应的值：" + name)
+			}
+
+			//切片展开为多个占位符，[]byte除外
+			v := reflect.ValueOf(value)
+			if (v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8) || v.Kind() == reflect.Array {
+				if v.Len() == 0 {
+					return "", nil, errors.New("命名参数" + name + "的切片不能为空")
+				}
+				for k := 0; k < v.Len(); k++ {
+					if k > 0 {
+						buf.WriteByte(',')
+					}
+					bound = append(bound, v.Index(k).Interface())
+					buf.WriteString(d.Placeholder(len(bound)))
+				}
+			} else {
+				bound = append(bound, value)
+				buf.WriteString(d.Placeholder(len(bound)))
+			}
+			i = j - 1
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), bound, nil
+}
+
+// 按名称取值，参数不是map[string]any或结构体时返回nil
+func namedLookup(arg interface{}) func(name string) (interface{}, bool) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			value, ok := m[name]
+			return value, ok
+		}
+	}
+
+	//time.Time等实现了Valuer的结构体是普通参数
+	if _, ok := arg.(driver.Valuer); ok {
+		return nil
+	}
+	if _, ok := arg.(time.Time); ok {
+		return nil
+	}
+	v := indirectValue(reflect.ValueOf(arg))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	s := parseSchema(v.Type())
+	return func(name string) (interface{}, bool) {
+		field := s.fieldByColumn(name)
+		if field == nil {
+			return nil, false
+		}
+		return v.Field(field.Index).Interface(), true
+	}
+}
+
+// mysql的--注释后面必须是空白字符
+func isLineComment(s string) bool {
+	return strings.HasPrefix(s, "--") && (len(s) == 2 || s[2] == ' ' || s[2] == '\t' || s[2] == '\n' || s[2] == '\r')
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
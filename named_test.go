@@ -0,0 +1,195 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type namedUser struct {
+	Uid  int64  `sql:"uid"`
+	Name string `sql:"name"`
+}
+
+func TestBindNamed(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		want     string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "map",
+			query:    "select * from user where uid=:uid and name=@name",
+			args:     []interface{}{map[string]interface{}{"uid": 1, "name": "a"}},
+			want:     "select * from user where uid=? and name=?",
+			wantArgs: []interface{}{1, "a"},
+		},
+		{
+			name:     "repeated name",
+			query:    "where a=:v or b=:v",
+			args:     []interface{}{map[string]interface{}{"v": 2}},
+			want:     "where a=? or b=?",
+			wantArgs: []interface{}{2, 2},
+		},
+		{
+			name:     "struct by sql tag",
+			query:    "where uid=:uid and name=:name",
+			args:     []interface{}{&namedUser{Uid: 3, Name: "b"}},
+			want:     "where uid=? and name=?",
+			wantArgs: []interface{}{int64(3), "b"},
+		},
+		{
+			name:     "cast",
+			query:    "select a::int from t where b=:b",
+			args:     []interface{}{map[string]interface{}{"b": 1}},
+			want:     "select a::int from t where b=?",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "system variable",
+			query:    "select @@version, :b",
+			args:     []interface{}{map[string]interface{}{"b": 1}},
+			want:     "select @@version, ?",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "assignment",
+			query:    "select x:=1, :b",
+			args:     []interface{}{map[string]interface{}{"b": 1}},
+			want:     "select x:=1, ?",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "quoted",
+			query:    "where a=':a' and b=\":b\" and `:c`=:d",
+			args:     []interface{}{map[string]interface{}{"d": 1}},
+			want:     "where a=':a' and b=\":b\" and `:c`=?",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "escaped quote",
+			query:    `where a='it\'s :a' and b=:b`,
+			args:     []interface{}{map[string]interface{}{"b": 1}},
+			want:     `where a='it\'s :a' and b=?`,
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "doubled quote",
+			query:    `where a='it''s :a' and b=:b`,
+			args:     []interface{}{map[string]interface{}{"b": 1}},
+			want:     `where a='it''s :a' and b=?`,
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "line comment",
+			query:    "where a=:a -- :zz\nand b=:b",
+			args:     []interface{}{map[string]interface{}{"a": 1, "b": 2}},
+			want:     "where a=? -- :zz\nand b=?",
+			wantArgs: []interface{}{1, 2},
+		},
+		{
+			name:     "line comment at end",
+			query:    "where a=:a -- :zz",
+			args:     []interface{}{map[string]interface{}{"a": 1}},
+			want:     "where a=? -- :zz",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "hash comment",
+			query:    "where a=:a # @zz",
+			args:     []interface{}{map[string]interface{}{"a": 1}},
+			want:     "where a=? # @zz",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "block comment",
+			query:    "select /* :zz */ a from t where a=:a /**/ and b=:b",
+			args:     []interface{}{map[string]interface{}{"a": 1, "b": 2}},
+			want:     "select /* :zz */ a from t where a=? /**/ and b=?",
+			wantArgs: []interface{}{1, 2},
+		},
+		{
+			name:     "double minus without space",
+			query:    "where a=1--:a",
+			args:     []interface{}{map[string]interface{}{"a": 1}},
+			want:     "where a=1--?",
+			wantArgs: []interface{}{1},
+		},
+		{
+			name:     "slice",
+			query:    "where uid in (:ids) and status=:status",
+			args:     []interface{}{map[string]interface{}{"ids": []int{1, 2, 3}, "status": 1}},
+			want:     "where uid in (?,?,?) and status=?",
+			wantArgs: []interface{}{1, 2, 3, 1},
+		},
+		{
+			name:     "array",
+			query:    "where uid in (:ids)",
+			args:     []interface{}{map[string]interface{}{"ids": [2]string{"a", "b"}}},
+			want:     "where uid in (?,?)",
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{
+			name:     "bytes",
+			query:    "where data=:data",
+			args:     []interface{}{map[string]interface{}{"data": []byte("ab")}},
+			want:     "where data=?",
+			wantArgs: []interface{}{[]byte("ab")},
+		},
+		{
+			name:     "positional args",
+			query:    "where a=? and b=:b",
+			args:     []interface{}{1, 2},
+			want:     "where a=? and b=:b",
+			wantArgs: []interface{}{1, 2},
+		},
+		{
+			name:     "time is positional",
+			query:    "where created=?",
+			args:     []interface{}{at},
+			want:     "where created=?",
+			wantArgs: []interface{}{at},
+		},
+		{
+			name:    "missing value",
+			query:   "where a=:a",
+			args:    []interface{}{map[string]interface{}{}},
+			wantErr: true,
+		},
+		{
+			name:    "missing struct field",
+			query:   "where a=:status",
+			args:    []interface{}{namedUser{}},
+			wantErr: true,
+		},
+		{
+			name:    "empty slice",
+			query:   "where uid in (:ids)",
+			args:    []interface{}{map[string]interface{}{"ids": []int{}}},
+			wantErr: true,
+		},
+	}
+
+	e := &Orm{Dialect: MysqlDialect{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := e.bindNamed(tt.query, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("bindNamed() = %q, %v, want error", got, args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bindNamed() error = %v", err)
+			}
+			if got != tt.want || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Fatalf("bindNamed() = %q, %v, want %q, %v", got, args, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
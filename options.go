@@ -0,0 +1,136 @@
+package orm
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 连接选项，用于NewMysql和NewFromDSN
+type Option func(o *options)
+
+type options struct {
+	dsn             *mysql.Config
+	dsnErr          error
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+	setMaxIdleConns bool
+}
+
+// 最大打开的连接数，默认不限制
+func WithMaxOpenConns(n int) Option {
+	return func(o *options) {
+		o.maxOpenConns = n
+	}
+}
+
+// 最大空闲连接数，默认为2，<=0时不保留空闲连接
+func WithMaxIdleConns(n int) Option {
+	return func(o *options) {
+		o.maxIdleConns = n
+		o.setMaxIdleConns = true
+	}
+}
+
+// 连接最长使用时间，超过后关闭重连，应小于mysql的wait_timeout
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.connMaxLifetime = d
+	}
+}
+
+// 连接最长空闲时间
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(o *options) {
+		o.connMaxIdleTime = d
+	}
+}
+
+// 字符集和排序规则，如WithCharset("utf8mb4", "utf8mb4_unicode_ci")，collation可为空
+func WithCharset(charset string, collation string) Option {
+	return func(o *options) {
+		if err := o.dsn.Apply(mysql.Charset(charset, collation)); err != nil && o.dsnErr == nil {
+			o.dsnErr = err
+		}
+	}
+}
+
+// 使用TLS连接
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		o.dsn.TLS = config
+	}
+}
+
+// 建立连接、读、写的超时时间，为0时不修改
+func WithTimeout(dial time.Duration, read time.Duration, write time.Duration) Option {
+	return func(o *options) {
+		if dial > 0 {
+			o.dsn.Timeout = dial
+		}
+		if read > 0 {
+			o.dsn.ReadTimeout = read
+		}
+		if write > 0 {
+			o.dsn.WriteTimeout = write
+		}
+	}
+}
+
+// time.Time的时区，与WithParseTime配合使用
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.dsn.Loc = loc
+	}
+}
+
+// 将DATE、DATETIME解析为time.Time
+func WithParseTime(parseTime bool) Option {
+	return func(o *options) {
+		o.dsn.ParseTime = parseTime
+	}
+}
+
+// 通过完整的dsn新建连接，如"user:pass@tcp(127.0.0.1:3306)/db?charset=utf8mb4"，opts会覆盖dsn中的对应参数
+func NewFromDSN(dsn string, opts ...Option) (*Orm, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return open(cfg, opts)
+}
+
+// 按选项打开连接池，并Ping确认连接可用
+func open(cfg *mysql.Config, opts []Option) (*Orm, error) {
+	o := &options{dsn: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.dsnErr != nil {
+		return nil, o.dsnErr
+	}
+
+	connector, err := mysql.NewConnector(o.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+
+	db.SetMaxOpenConns(o.maxOpenConns)
+	if o.setMaxIdleConns {
+		db.SetMaxIdleConns(o.maxIdleConns)
+	}
+	db.SetConnMaxLifetime(o.connMaxLifetime)
+	db.SetConnMaxIdleTime(o.connMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return newOrm(db), nil
+}
@@ -1,72 +1,252 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type Orm struct {
-	Db           *sql.DB
-	FieldParam   string
-	TableName    string
-	WhereParam   string
-	OrWhereParam string
-	WhereExec    []interface{}
-	GroupParam   string
-	HavingParam  string
-	OrderParam   string
-	LimitParam   string
-	Prepare      string
-	AllExec      []interface{}
-	Sql          string
-	UpdateParam  string
-	UpdateExec   []interface{}
-	Tx           *sql.Tx
-	TransStatus  int
-}
-
-// 新建Mysql连接
-func NewMysql(Username string, Password string, Address string, Dbname string) (*Orm, error) {
-	dsn := Username + ":" + Password + "@tcp(" + Address + ")/" + Dbname + "?charset=utf8&timeout=5s&readTimeout=6s"
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
+	Db               *sql.DB
+	FieldParam       string
+	TableName        string
+	WhereParam       string
+	OrWhereParam     string
+	WhereExec        []interface{}
+	GroupParam       string
+	HavingParam      string
+	OrderParam       string
+	LimitParam       string
+	Prepare          string
+	AllExec          []interface{}
+	Sql              string
+	UpdateParam      string
+	UpdateExec       []interface{}
+	Tx               *sql.Tx
+	TransStatus      int
+	Dialect          Dialect
+	ModelType        reflect.Type
+	ModelValue       reflect.Value
+	IsUnscoped       bool
+	NowFunc          func() time.Time
+	LockParam        string
+	LockOption       string
+	PreloadParam     []string
+	RawSql           string
+	RawExec          []interface{}
+	Interceptors     []Interceptor
+	Logger           Logger
+	SlowThreshold    time.Duration
+	DisableCaller    bool
+	StmtCache        *StmtCache               //预处理语句缓存，为nil时每次执行后关闭语句
+	DisablePrepare   bool                     //为true时不预处理，直接Exec
+	Replicas         []*sql.DB                //从库
+	Policy           ReplicaPolicy            //从库选择策略
+	IsPrimary        bool                     //本次查询使用主库
+	Sharding         *Sharding                //分片插件
+	ShardDb          *sql.DB                  //路由到的分片所在的库
+	WhereKeys        map[string][]interface{} //where中等值和in条件的值，用于分片路由
+	IsOrWhere        bool                     //where中包含or条件
+	IsScatter        bool                     //允许在多个分片上执行
+	LogicalTable     string                   //分片前的逻辑表名
+	Ctx              context.Context          //执行语句的上下文，多租户的租户ID从中读取
+	TenantColumn     string                   //租户字段
+	TenantTables     map[string]bool          //按租户隔离的表
+	IsSkipTenant     bool                     //本次操作不按租户隔离
+	SoftDeleteTables map[string]reflect.Type  //按表注册的软删除模型
+	SoftDeleteCond   string                   //软删除和恢复时删除时间字段的条件，如is null
+	IsInsertKey      bool                     //插入时包含自增字段，Save()按已有主键插入时使用
+	Err              error
+}
+
+// 执行sql的对象，*sql.DB和*sql.Tx均满足
+type sqlExecutor interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// 批量插入的结果
+type BatchResult struct {
+	RowsAffected  int64 //插入的总行数
+	FirstInsertId int64 //第一条的自增ID
+	LastInsertId  int64 //最后一条的自增ID
+}
+
+// 原生Exec的结果
+type ExecResult struct {
+	LastInsertId int64 //自增ID，非insert语句为0
+	RowsAffected int64 //影响的行数
+}
+
+// mysql单条语句占位符个数上限
+const maxPlaceholders = 65535
+
+// 新建Mysql连接，默认字符集utf8、连接超时5s、读超时6s，可通过opts设置连接池、字符集、TLS等，新建时会Ping确认连接可用
+func NewMysql(Username string, Password string, Address string, Dbname string, opts ...Option) (*Orm, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = Username
+	cfg.Passwd = Password
+	cfg.Net = "tcp"
+	cfg.Addr = Address
+	cfg.DBName = Dbname
+	cfg.Timeout = 5 * time.Second
+	cfg.ReadTimeout = 6 * time.Second
+	if err := cfg.Apply(mysql.Charset("utf8", "")); err != nil {
 		return nil, err
 	}
+	return open(cfg, opts)
+}
 
-	//最大连接数等配置，先占个位
-	//db.SetMaxOpenConns(3)
-	//db.SetMaxIdleConns(3)
-
+// 新建Orm，设置默认的方言和预处理语句缓存
+func newOrm(db *sql.DB) *Orm {
 	return &Orm{
 		Db:         db,
 		FieldParam: "*",
-	}, nil
+		Dialect:    MysqlDialect{},
+		StmtCache:  NewStmtCache(defaultStmtCacheSize),
+	}
 }
 
 // 设置表名
 func (e *Orm) Table(name string) *Orm {
+	e.resetOrm()
 	e.TableName = name
-	// e.resetOrm()
+	if !identifierPattern.MatchString(name) {
+		return e.addError(errors.New("非法的表名：" + name))
+	}
 	return e
 }
 
+// 重置查询条件，数据库连接和事务状态保留
+func (e *Orm) resetOrm() {
+	e.FieldParam = "*"
+	e.TableName = ""
+	e.WhereParam = ""
+	e.OrWhereParam = ""
+	e.WhereExec = nil
+	e.GroupParam = ""
+	e.HavingParam = ""
+	e.OrderParam = ""
+	e.LimitParam = ""
+	e.Prepare = ""
+	e.AllExec = nil
+	e.UpdateParam = ""
+	e.UpdateExec = nil
+	e.ModelType = nil
+	e.ModelValue = reflect.Value{}
+	e.IsUnscoped = false
+	e.LockParam = ""
+	e.LockOption = ""
+	e.PreloadParam = nil
+	e.RawSql = ""
+	e.IsPrimary = false
+	e.ShardDb = nil
+	e.WhereKeys = nil
+	e.IsOrWhere = false
+	e.IsScatter = false
+	e.LogicalTable = ""
+	e.IsSkipTenant = false
+	e.SoftDeleteCond = ""
+	e.IsInsertKey = false
+	e.RawExec = nil
+	e.Err = nil
+}
+
+// 新建一个共享数据库连接的查询构造器，如用于InsertSelect的子查询
+func (e *Orm) Session() *Orm {
+	s := *e
+	s.resetOrm()
+	return &s
+}
+
 // 获取表名
 func (e *Orm) GetTable() string {
 	return e.TableName
 }
 
+// 获取当前执行sql的对象，开启了事务则使用事务
+// 主库或当前事务，原生sql不参与分片路由
+func (e *Orm) primaryExecutor() sqlExecutor {
+	if e.TransStatus == 1 && e.Tx != nil {
+		return e.Tx
+	}
+	return e.Db
+}
+
+func (e *Orm) getExecutor() sqlExecutor {
+	if e.TransStatus == 1 && e.Tx != nil {
+		return e.Tx
+	}
+	return e.getDb()
+}
+
 func (e *Orm) doInsert(batchData interface{}, insertType string) (int64, error) {
+	defer e.unroute()
+	result, err := e.execInsert(batchData, insertType)
+	if err != nil {
+		return 0, err
+	}
+
+	//获取自增ID
+	id, _ := result.LastInsertId()
+	return id, nil
+}
+
+func (e *Orm) execInsert(batchData interface{}, insertType string) (sql.Result, error) {
+	if err := e.checkTenant(); err != nil {
+		return nil, err
+	}
+
 	//反射解析
-	getValue := reflect.ValueOf(batchData)
+	getValue := indirectValue(reflect.ValueOf(batchData))
+
+	//每个子元素，用于调用钩子和回写自增ID
+	items := make([]reflect.Value, getValue.Len())
+	for i := range items {
+		value := indirectValue(getValue.Index(i)) // Value of item
+		if value.Kind() != reflect.Struct {
+			return nil, e.setErrorInfo(errors.New("批量插入的子元素必须是结构体类型"))
+		}
+		items[i] = value
+
+		//插入前钩子，返回错误时终止插入
+		if err := e.callHook(value, beforeInsert); err != nil {
+			return nil, err
+		}
+	}
+
+	//按分片字段路由
+	if err := e.routeInsert(items); err != nil {
+		return nil, err
+	}
 
+	result, err := e.insertItems(items, insertType)
+	if err != nil {
+		return nil, err
+	}
+
+	//插入后钩子
+	for _, item := range items {
+		if err := e.callHook(item, afterInsert); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (e *Orm) insertItems(items []reflect.Value, insertType string) (sql.Result, error) {
 	//切片大小
-	l := getValue.Len()
+	l := len(items)
 
 	//字段名
 	var fieldName []string
@@ -74,49 +254,52 @@ func (e *Orm) doInsert(batchData interface{}, insertType string) (int64, error)
 	//占位符
 	var placeholderString []string
 
-	//循环判断
-	for i := 0; i < l; i++ {
-		value := getValue.Index(i) // Value of item
-		typed := value.Type()      // Type of item
-		if typed.Kind() != reflect.Struct {
-			panic("批量插入的子元素必须是结构体类型")
-		}
+	//多次执行时清空上一次的值
+	e.AllExec = nil
 
-		num := value.NumField()
+	//同一条语句使用相同的创建/更新时间
+	now := e.now()
+
+	//多租户表填充租户字段
+	tenantColumn, tenant, tenantScoped := e.tenantScope()
+
+	//循环判断
+	var s *schema
+	for i, value := range items {
+		s = parseSchema(value.Type())
+		fields, appendTenant := e.insertFields(s)
 
 		//子元素值
 		var placeholder []string
 		//循环遍历子元素
-		for j := 0; j < num; j++ {
-
-			//小写开头，无法反射，跳过
-			if !value.Field(j).CanInterface() {
-				continue
+		for _, field := range fields {
+			//字段名只记录第一个的
+			if i == 0 {
+				fieldName = append(fieldName, e.getDialect().Quote(field.Column))
 			}
+			placeholder = append(placeholder, "?")
 
-			//解析tag，找出真实的sql字段名
-			sqlTag := typed.Field(j).Tag.Get("sql")
-			if sqlTag != "" {
-				//跳过自增字段
-				if strings.Contains(strings.ToLower(sqlTag), "auto_increment") {
-					continue
-				} else {
-					//字段名只记录第一个的
-					if i == 1 {
-						fieldName = append(fieldName, strings.Split(sqlTag, ",")[0])
-					}
-					placeholder = append(placeholder, "?")
+			//字段值，创建/更新时间字段为空时自动填充
+			if tenantScoped && field.Column == tenantColumn {
+				v, err := e.tenantValue(value, field, tenant)
+				if err != nil {
+					return nil, err
 				}
+				e.AllExec = append(e.AllExec, v)
+			} else if (field.AutoCreateTime || field.AutoUpdateTime) && value.Field(field.Index).IsZero() {
+				e.AllExec = append(e.AllExec, fillTime(value, field, now))
 			} else {
-				//字段名只记录第一个的
-				if i == 1 {
-					fieldName = append(fieldName, typed.Field(j).Name)
-				}
-				placeholder = append(placeholder, "?")
+				e.AllExec = append(e.AllExec, value.Field(field.Index).Interface())
 			}
+		}
 
-			//字段值
-			e.AllExec = append(e.AllExec, value.Field(j).Interface())
+		//结构体中没有租户字段时追加
+		if appendTenant {
+			if i == 0 {
+				fieldName = append(fieldName, e.getDialect().Quote(tenantColumn))
+			}
+			placeholder = append(placeholder, "?")
+			e.AllExec = append(e.AllExec, tenant)
 		}
 
 		//子元素拼接成多个()括号后的值
@@ -124,66 +307,370 @@ func (e *Orm) doInsert(batchData interface{}, insertType string) (int64, error)
 	}
 
 	//拼接表，字段名，占位符
-	e.Prepare = insertType + " into " + e.GetTable() + " (" + strings.Join(fieldName, ",") + ") values " + strings.Join(placeholderString, ",")
+	e.Prepare = insertType + " into " + e.quoteTable() + " (" + strings.Join(fieldName, ",") + ") values " + strings.Join(placeholderString, ",")
+
+	//支持returning的数据库直接返回每一行的自增ID
+	if s != nil && s.AutoIncrement != nil && e.getDialect().SupportReturning() {
+		return e.execInsertReturning(items, s.AutoIncrement)
+	}
 
-	//prepare
-	var stmt *sql.Stmt
-	var err error
-	stmt, err = e.Db.Prepare(e.Prepare)
+	//执行
+	result, err := e.execStatement(OpInsert, e.Prepare, e.AllExec)
 	if err != nil {
-		return 0, e.setErrorInfo(err)
+		return nil, e.setErrorInfo(err)
 	}
 
-	//执行exec,注意这是stmt.Exec
-	result, err := stmt.Exec(e.AllExec...)
+	//回写自增ID，mysql多行插入时LastInsertId为第一条的ID，后续连续递增
+	//insert ignore跳过的行、replace删除的行会使影响行数对不上，此时无法确定每一行的ID，不回写
+	if s != nil && s.AutoIncrement != nil {
+		id, err := result.LastInsertId()
+		rowsAffected, _ := result.RowsAffected()
+		if err == nil && id > 0 && rowsAffected == int64(l) {
+			for i, item := range items {
+				if err := e.setAutoIncrement(item, s.AutoIncrement, id+int64(i)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// insert ... returning自增字段，并回写到每个子元素
+func (e *Orm) execInsertReturning(items []reflect.Value, field *schemaField) (sql.Result, error) {
+	e.Prepare += " returning " + field.Column
+
+	var ids []int64
+	err := e.queryStatement(OpInsert, e.Prepare, e.AllExec, func(rows *sql.Rows) (int64, error) {
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return int64(len(ids)), err
+			}
+			ids = append(ids, id)
+		}
+		return int64(len(ids)), nil
+	})
 	if err != nil {
-		return 0, e.setErrorInfo(err)
+		return nil, e.setErrorInfo(err)
 	}
 
-	//获取自增ID
-	id, _ := result.LastInsertId()
-	return id, nil
+	//returning的行与插入的行一一对应
+	if len(ids) == len(items) {
+		for i, item := range items {
+			if err := e.setAutoIncrement(item, field, ids[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := execResult{rowsAffected: int64(len(ids))}
+	if len(ids) > 0 {
+		result.lastInsertId = ids[0]
+	}
+	return result, nil
+}
+
+// 回写自增ID，传入的不是指针时无法回写，跳过
+func (e *Orm) setAutoIncrement(item reflect.Value, field *schemaField, id int64) error {
+	if !item.CanSet() {
+		return nil
+	}
+	return e.reflectSet(item, field.Index, strconv.FormatInt(id, 10))
+}
+
+// 自定义的执行结果，用于returning方式插入和拦截器短路，与mysql一致，LastInsertId为第一条的ID
+type execResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// 分批插入，每批最多batchSize条，超过占位符上限时自动调小，所有批次在同一个事务中执行
+func (e *Orm) InsertBatch(data interface{}, batchSize int) (BatchResult, error) {
+	var res BatchResult
+	if e.Err != nil {
+		return res, e.Err
+	}
+
+	getValue := reflect.Indirect(reflect.ValueOf(data))
+	if getValue.Kind() == reflect.Array {
+		//数组转为切片，便于分批
+		arr := reflect.New(getValue.Type()).Elem()
+		arr.Set(getValue)
+		getValue = arr.Slice(0, arr.Len())
+	}
+	if getValue.Kind() != reflect.Slice {
+		return res, e.setErrorInfo(errors.New("批量插入的数据格式不正确，格式为: []struct"))
+	}
+	if batchSize <= 0 {
+		return res, e.setErrorInfo(errors.New("batchSize必须大于0"))
+	}
+
+	l := getValue.Len()
+	if l == 0 {
+		return res, nil
+	}
+
+	if err := e.checkTenant(); err != nil {
+		return res, err
+	}
+
+	//所有数据按分片字段路由到同一个分片，事务在分片所在的库上开启
+	items := make([]reflect.Value, l)
+	for i := range items {
+		items[i] = indirectValue(getValue.Index(i))
+		if items[i].Kind() != reflect.Struct {
+			return res, e.setErrorInfo(errors.New("批量插入的子元素必须是结构体类型"))
+		}
+	}
+	if err := e.routeInsert(items); err != nil {
+		return res, err
+	}
+	defer e.unroute()
+
+	//按字段数限制每批条数，保证占位符不超过上限
+	if columnNum := e.insertColumnNum(getValue.Index(0)); columnNum > 0 && batchSize*columnNum > maxPlaceholders {
+		batchSize = maxPlaceholders / columnNum
+	}
+
+	//没有自增字段时不计算自增ID
+	autoIncrement := parseSchema(items[0].Type()).AutoIncrement != nil
+
+	//所有批次在同一个事务中执行，未开启事务时自动开启
+	err := e.transaction(func() error {
+		for start := 0; start < l; start += batchSize {
+			end := start + batchSize
+			if end > l {
+				end = l
+			}
+
+			result, err := e.execInsert(getValue.Slice(start, end).Interface(), "insert")
+			if err != nil {
+				return err
+			}
+
+			rowsAffected, _ := result.RowsAffected()
+			id, _ := result.LastInsertId()
+
+			res.RowsAffected += rowsAffected
+
+			//mysql多行插入时LastInsertId为该批第一条的ID，自增ID连续
+			if !autoIncrement || id <= 0 {
+				continue
+			}
+			if res.FirstInsertId == 0 {
+				res.FirstInsertId = id
+			}
+			res.LastInsertId = id + rowsAffected - 1
+		}
+		return nil
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return res, nil
+}
+
+// 统计单条插入数据的字段数，与insertItems使用相同的字段
+func (e *Orm) insertColumnNum(value reflect.Value) int {
+	value = indirectValue(value)
+	if value.Kind() != reflect.Struct {
+		return 0
+	}
+
+	fields, appendTenant := e.insertFields(parseSchema(value.Type()))
+	if appendTenant {
+		return len(fields) + 1
+	}
+	return len(fields)
 }
 
-// 自定义错误格式
+// 插入的字段，跳过软删除字段和自增字段（IsInsertKey为true时保留）；appendTenant为true时结构体中没有租户字段，需追加租户字段
+func (e *Orm) insertFields(s *schema) (fields []*schemaField, appendTenant bool) {
+	for _, field := range s.Fields {
+		if (!field.AutoIncrement || e.IsInsertKey) && !field.SoftDelete {
+			fields = append(fields, field)
+		}
+	}
+	column, _, scoped := e.tenantScope()
+	return fields, scoped && s.fieldByColumn(column) == nil
+}
+
+// 记录构造器链上的第一个错误，由Select、Find、Update等执行方法返回，不再panic
+func (e *Orm) addError(err error) *Orm {
+	if e.Err == nil {
+		e.Err = e.wrapError(err, 2)
+	}
+	return e
+}
+
+// 自定义错误格式，驱动错误转为对应的哨兵错误，并记录调用位置（DisableCaller为true时不记录）
 func (e *Orm) setErrorInfo(err error) error {
-	_, file, line, _ := runtime.Caller(1)
-	return errors.New("File: " + file + ":" + strconv.Itoa(line) + ", " + err.Error())
+	return e.wrapError(err, 2)
 }
 
-// 插入
-func (e *Orm) Insert(data interface{}) (int64, error) {
-	//判断是批量还是单个插入
-	getValue := reflect.ValueOf(data).Kind()
-	if getValue == reflect.Struct {
-		return e.doInsert([]any{data}, "insert")
-	} else if getValue == reflect.Slice || getValue == reflect.Array {
-		return e.doInsert(data, "insert")
-	} else {
-		return 0, errors.New("插入的数据格式不正确，单个插入格式为: struct，批量插入格式为: []struct")
+// skip为runtime.Caller的层数，记录调用方的位置
+func (e *Orm) wrapError(err error, skip int) error {
+	if err == nil {
+		return nil
 	}
+
+	//已处理过的错误，保留最里层的调用位置
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return err
+	}
+
+	err = e.getDialect().TranslateError(err)
+	if e.DisableCaller {
+		return err
+	}
+
+	_, file, line, _ := runtime.Caller(skip)
+	return &Error{File: file, Line: line, Err: err}
+}
+
+// 插入，传入指针时回写自增ID
+func (e *Orm) Insert(data interface{}) (int64, error) {
+	return e.insertData(data, "insert")
 }
 func (e *Orm) Replace(data interface{}) (int64, error) {
+	return e.insertData(data, "replace")
+}
+
+// 插入，忽略唯一键冲突的行
+func (e *Orm) InsertIgnore(data interface{}) (int64, error) {
+	return e.insertData(data, "insert ignore")
+}
+
+func (e *Orm) insertData(data interface{}, insertType string) (int64, error) {
+	if e.Err != nil {
+		return 0, e.Err
+	}
+
 	//判断是批量还是单个插入
-	getValue := reflect.ValueOf(data).Kind()
-	if getValue == reflect.Struct {
-		return e.doInsert([]any{data}, "replace")
-	} else if getValue == reflect.Slice || getValue == reflect.Array {
-		return e.doInsert(data, "replace")
+	getValue := reflect.Indirect(reflect.ValueOf(data))
+	if getValue.Kind() == reflect.Struct {
+		return e.doInsert([]any{data}, insertType)
+	} else if getValue.Kind() == reflect.Slice || getValue.Kind() == reflect.Array {
+		//空切片不插入
+		if getValue.Len() == 0 {
+			return 0, nil
+		}
+		return e.doInsert(data, insertType)
 	} else {
 		return 0, errors.New("插入的数据格式不正确，单个插入格式为: struct，批量插入格式为: []struct")
 	}
 }
+
+// insert into ... select ...，query为Session()创建的查询构造器，返回影响的行数
+func (e *Orm) InsertSelect(columns []string, query *Orm) (int64, error) {
+	if query == nil {
+		return 0, e.setErrorInfo(errors.New("InsertSelect的查询构造器不能为空"))
+	}
+	if e.Err != nil {
+		return 0, e.Err
+	}
+	if query.Err != nil {
+		return 0, query.Err
+	}
+
+	if err := e.checkTenant(); err != nil {
+		return 0, err
+	}
+	if err := query.checkTenant(); err != nil {
+		return 0, err
+	}
+
+	//不支持跨分片，查询与插入的表必须路由到同一个库
+	defer e.unroute()
+	defer query.unroute()
+	for _, s := range []*Orm{e, query} {
+		if shards, err := s.route(); err != nil {
+			return 0, e.setErrorInfo(err)
+		} else if len(shards) > 1 {
+			return 0, e.setErrorInfo(errors.New("InsertSelect不支持跨分片"))
+		}
+	}
+	if e.getDb() != query.getDb() {
+		return 0, e.setErrorInfo(errors.New("InsertSelect的查询与插入的表必须在同一个库"))
+	}
+
+	//拼接表，字段名，子查询
+	e.Prepare = "insert into " + e.quoteTable()
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		c, err := e.quoteColumn(column)
+		if err != nil {
+			return 0, e.setErrorInfo(err)
+		}
+		quoted[i] = c
+	}
+
+	//多租户表追加租户字段，值为当前租户ID，作为查询的最后一个字段
+	tenantColumn, tenant, tenantScoped := e.tenantScope()
+	source := *query
+	if tenantScoped {
+		if len(columns) == 0 {
+			return 0, e.setErrorInfo(errors.New("多租户表" + e.logicalTable() + "的InsertSelect必须指定插入的字段"))
+		}
+		for _, column := range columns {
+			if column == tenantColumn {
+				return 0, e.setErrorInfo(errors.New("不能插入租户字段" + tenantColumn + "，租户ID自动填充"))
+			}
+		}
+		quoted = append(quoted, e.getDialect().Quote(tenantColumn))
+		if source.FieldParam == "" {
+			source.FieldParam = "*"
+		}
+		source.FieldParam += ",?"
+	}
+	if len(quoted) > 0 {
+		e.Prepare += " (" + strings.Join(quoted, ",") + ")"
+	}
+
+	//租户ID在查询字段中，参数在where参数之前
+	selectSql, args := source.buildSelect()
+	if tenantScoped {
+		args = append([]interface{}{tenant}, args...)
+	}
+	e.Prepare += " " + selectSql
+	e.AllExec = args
+
+	//执行
+	result, err := e.execStatement(OpInsert, e.Prepare, e.AllExec)
+	if err != nil {
+		return 0, e.setErrorInfo(err)
+	}
+
+	//影响的行数
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, e.setErrorInfo(err)
+	}
+	return rowsAffected, nil
+}
+
 func (e *Orm) Where(data ...interface{}) *Orm {
-	e.doWhere(data, "and")
+	return e.doWhere(data, "and")
 }
 func (e *Orm) OrWhere(data ...interface{}) *Orm {
-	e.doWhere(data, "or")
+	return e.doWhere(data, "or")
 }
-func (e *Orm) doWhere(data ...interface{}, whereType string) *Orm {
+func (e *Orm) doWhere(data []interface{}, whereType string) *Orm {
 	//判断使用顺序
 	if whereType == "or" && e.WhereParam == "" {
-		panic("WhereOr必须在Where后面调用")
+		return e.addError(errors.New("WhereOr必须在Where后面调用"))
 	}
 
 	//判断是结构体还是多个字符串
@@ -195,113 +682,179 @@ func (e *Orm) doWhere(data ...interface{}, whereType string) *Orm {
 	} else if len(data) == 3 {
 		dataType = 3
 	} else {
-		panic("参数个数错误")
+		return e.addError(errors.New("参数个数错误"))
 	}
 
-	//多次调用判断
-	if e.WhereParam != "" {
-		e.WhereParam += " " + whereType + " ("
-	} else {
-		e.WhereParam += "("
+	//校验字段名和操作符
+	var column, operator string
+	if dataType >= 2 {
+		var err error
+		if column, err = e.quoteColumn(data[0]); err != nil {
+			return e.addError(err)
+		}
+	}
+	if dataType == 3 {
+		op, ok := data[1].(string)
+		if !ok {
+			return e.addError(errors.New("where的操作符必须是字符串"))
+		}
+		var err error
+		if operator, err = checkOperator(op); err != nil {
+			return e.addError(err)
+		}
 	}
 
 	//如果是结构体
 	if dataType == 1 {
-		t := reflect.TypeOf(data[0])
-		v := reflect.ValueOf(data[0])
+		v := indirectValue(reflect.ValueOf(data[0]))
+		if v.Kind() != reflect.Struct {
+			return e.addError(errors.New("单个参数时where条件必须是结构体"))
+		}
 
 		//字段名
 		var fieldNameArray []string
 
-		//循环解析
-		for i := 0; i < t.NumField(); i++ {
-
-			//首字母小写，不可反射
-			if !v.Field(i).CanInterface() {
-				continue
-			}
-
-			//解析tag，找出真实的sql字段名
-			sqlTag := t.Field(i).Tag.Get("sql")
-			if sqlTag != "" {
-				fieldNameArray = append(fieldNameArray, strings.Split(sqlTag, ",")[0]+"=?")
-			} else {
-				fieldNameArray = append(fieldNameArray, t.Field(i).Name+"=?")
-			}
-
-			e.WhereExec = append(e.WhereExec, v.Field(i).Interface())
+		//循环解析，关联字段不参与
+		for _, field := range parseSchema(v.Type()).Fields {
+			fieldNameArray = append(fieldNameArray, e.getDialect().Quote(field.Column)+"=?")
+			e.WhereExec = append(e.WhereExec, v.Field(field.Index).Interface())
+			e.addWhereKey(field.Column, v.Field(field.Index).Interface())
 		}
 
 		//拼接
-		e.WhereParam += strings.Join(fieldNameArray, " and ") + ") "
+		e.appendWhere(whereType, strings.Join(fieldNameArray, " and "))
 
 	} else if dataType == 2 {
 		//直接=的情况
-		e.WhereParam += data[0].(string) + "=?) "
+		e.appendWhere(whereType, column+"=?")
 		e.WhereExec = append(e.WhereExec, data[1])
+		e.addWhereKey(data[0], data[1])
 	} else if dataType == 3 {
 		//3个参数的情况
+		condition, args, err := buildCondition(column, operator, data[2])
+		if err != nil {
+			return e.addError(err)
+		}
+		e.appendWhere(whereType, condition)
+		e.WhereExec = append(e.WhereExec, args...)
+		if operator == "=" || operator == "in" {
+			e.addWhereKey(data[0], args...)
+		}
+	}
 
-		//区分是操作符in的情况
-		data2 := strings.Trim(strings.ToLower(data[1].(string)), " ")
-		if data2 == "in" || data2 == "not in" {
-			//判断传入的是切片
-			reType := reflect.TypeOf(data[2]).Kind()
-			if reType != reflect.Slice && reType != reflect.Array {
-				panic("in/not in 操作传入的数据必须是切片或者数组")
-			}
-
-			//反射值
-			v := reflect.ValueOf(data[2])
-			//数组/切片长度
-			dataNum := v.Len()
-			//占位符
-			ps := make([]string, dataNum)
-			for i := 0; i < dataNum; i++ {
-				ps[i] = "?"
-				e.WhereExec = append(e.WhereExec, v.Index(i).Interface())
-			}
+	//or条件无法确定分片
+	if whereType == "or" {
+		e.IsOrWhere = true
+	}
 
-			//拼接
-			e.WhereParam += data[0].(string) + " " + data2 + " (" + strings.Join(ps, ",") + ")) "
+	return e
+}
 
-		} else {
-			e.WhereParam += data[0].(string) + " " + data[1].(string) + " ?) "
-			e.WhereExec = append(e.WhereExec, data[2])
+// 拼接带操作符的条件，返回条件和对应的参数
+// in/not in、between/not between传入切片，is/is not只能与nil比较
+func buildCondition(column string, operator string, value interface{}) (string, []interface{}, error) {
+	switch operator {
+	case "in", "not in", "between", "not between":
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", nil, errors.New(operator + " 操作传入的数据必须是切片或者数组")
+		}
+		args := make([]interface{}, v.Len())
+		for i := range args {
+			args[i] = v.Index(i).Interface()
 		}
+		if operator == "between" || operator == "not between" {
+			if len(args) != 2 {
+				return "", nil, errors.New(operator + " 操作传入的数据必须是2个元素")
+			}
+			return column + " " + operator + " ? and ?", args, nil
+		}
+		if len(args) == 0 {
+			return "", nil, errors.New(operator + " 操作传入的数据不能为空")
+		}
+		ps := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+		return column + " " + operator + " (" + ps + ")", args, nil
+	case "is", "is not":
+		if value != nil {
+			return "", nil, errors.New(operator + " 操作只能与nil比较")
+		}
+		return column + " " + operator + " null", nil, nil
 	}
+	return column + " " + operator + " ?", []interface{}{value}, nil
+}
 
-	return e
+// 拼接一个where条件，多次调用时以and/or连接
+func (e *Orm) appendWhere(whereType string, condition string) {
+	if e.WhereParam != "" {
+		e.WhereParam += " " + whereType + " ("
+	} else {
+		e.WhereParam += "("
+	}
+	e.WhereParam += condition + ") "
 }
 
-// 删除
+// 删除，通过Model()或DeleteByPK()传入模型时调用删除钩子
 func (e *Orm) Delete() (int64, error) {
+	if e.Err != nil {
+		return 0, e.Err
+	}
+	if err := e.checkTenant(); err != nil {
+		return 0, err
+	}
 
-	//拼接delete sql
-	e.Prepare = "delete from " + e.GetTable()
+	//删除前钩子，返回错误时终止删除
+	if err := e.callHook(e.ModelValue, beforeDelete); err != nil {
+		return 0, err
+	}
 
-	//如果where不为空
-	if e.WhereParam != "" || e.OrWhereParam != "" {
-		e.Prepare += " where " + e.WhereParam + e.OrWhereParam
+	rowsAffected, err := e.doDelete()
+	if err != nil {
+		return 0, err
 	}
 
-	//limit不为空
-	if e.LimitParam != "" {
-		e.Prepare += "limit " + e.LimitParam
+	//删除后钩子
+	if err := e.callHook(e.ModelValue, afterDelete); err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+func (e *Orm) doDelete() (int64, error) {
+
+	//软删除，转为更新删除时间
+	if column := e.softDeleteColumn(); column != "" && !e.IsUnscoped {
+		return e.softDelete()
 	}
 
-	//第一步：Prepare
-	var stmt *sql.Stmt
-	var err error
-	stmt, err = e.Db.Prepare(e.Prepare)
+	//分片路由，多个分片时影响行数相加
+	defer e.unroute()
+	shards, err := e.route()
 	if err != nil {
 		return 0, err
 	}
+	if len(shards) > 1 {
+		var rowsAffected int64
+		err := e.scatter(shards, func(s *Orm) error {
+			n, err := s.doDelete()
+			rowsAffected += n
+			return err
+		})
+		return rowsAffected, err
+	}
+
+	//拼接delete sql
+	where, args := e.buildWhere(false)
+	e.Prepare = "delete from " + e.quoteTable() + where
+
+	//limit不为空
+	if e.LimitParam != "" {
+		e.Prepare += " limit " + e.LimitParam
+	}
 
-	e.AllExec = e.WhereExec
+	e.AllExec = args
 
-	//第二步：执行exec,注意这是stmt.Exec
-	result, err := stmt.Exec(e.AllExec...)
+	//执行
+	result, err := e.execStatement(OpDelete, e.Prepare, e.AllExec)
 	if err != nil {
 		return 0, e.setErrorInfo(err)
 	}
@@ -317,6 +870,15 @@ func (e *Orm) Delete() (int64, error) {
 
 // 更新
 func (e *Orm) Update(data ...interface{}) (int64, error) {
+	if e.Err != nil {
+		return 0, e.Err
+	}
+	if err := e.checkTenant(); err != nil {
+		return 0, err
+	}
+
+	//租户字段不允许修改
+	tenantColumn, _, tenantScoped := e.tenantScope()
 
 	//判断是结构体还是多个字符串
 	var dataType int
@@ -328,65 +890,118 @@ func (e *Orm) Update(data ...interface{}) (int64, error) {
 		return 0, errors.New("参数个数错误")
 	}
 
+	//乐观锁版本号字段
+	var v reflect.Value
+	var version *schemaField
+
 	//如果是结构体
 	if dataType == 1 {
-		t := reflect.TypeOf(data[0])
-		v := reflect.ValueOf(data[0])
+		v = indirectValue(reflect.ValueOf(data[0]))
+		if v.Kind() != reflect.Struct {
+			return 0, e.setErrorInfo(errors.New("单个参数更新时参数必须是结构体"))
+		}
 
+		//更新前钩子，返回错误时终止更新
+		if err := e.callHook(v, beforeUpdate); err != nil {
+			return 0, err
+		}
+
+		now := e.now()
 		var fieldNameArray []string
-		for i := 0; i < t.NumField(); i++ {
+		for _, field := range parseSchema(v.Type()).Fields {
 
-			//首字母小写，不可反射
-			if !v.Field(i).CanInterface() {
+			//主键、软删除、创建时间和租户字段不更新
+			if field.PrimaryKey || field.SoftDelete || field.AutoCreateTime || (tenantScoped && field.Column == tenantColumn) {
 				continue
 			}
 
-			//解析tag，找出真实的sql字段名
-			sqlTag := t.Field(i).Tag.Get("sql")
-			if sqlTag != "" {
-				fieldNameArray = append(fieldNameArray, strings.Split(sqlTag, ",")[0]+"=?")
-			} else {
-				fieldNameArray = append(fieldNameArray, t.Field(i).Name+"=?")
+			//版本号自增，并以当前版本号作为条件
+			if field.Version {
+				version = field
+				column := e.getDialect().Quote(field.Column)
+				fieldNameArray = append(fieldNameArray, column+"="+column+"+1")
+				e.whereVersion(field.Column, v.Field(field.Index).Interface())
+				continue
 			}
 
-			e.UpdateExec = append(e.UpdateExec, v.Field(i).Interface())
+			fieldNameArray = append(fieldNameArray, e.getDialect().Quote(field.Column)+"=?")
+
+			//更新时间字段自动填充
+			if field.AutoUpdateTime {
+				e.UpdateExec = append(e.UpdateExec, fillTime(v, field, now))
+			} else {
+				e.UpdateExec = append(e.UpdateExec, v.Field(field.Index).Interface())
+			}
 		}
 		e.UpdateParam += strings.Join(fieldNameArray, ",")
 
 	} else if dataType == 2 {
 		//直接=的情况
-		e.UpdateParam += data[0].(string) + "=?"
+		column, err := e.quoteColumn(data[0])
+		if err != nil {
+			return 0, e.setErrorInfo(err)
+		}
+		if name, ok := data[0].(string); ok && tenantScoped && name == tenantColumn {
+			return 0, e.setErrorInfo(errors.New("不能修改租户字段" + tenantColumn))
+		}
+		e.UpdateParam += column + "=?"
 		e.UpdateExec = append(e.UpdateExec, data[1])
+		if name, ok := data[0].(string); ok {
+			e.appendUpdateTime(name)
+		}
 	}
 
-	//拼接sql
-	e.Prepare = "update " + e.GetTable() + " set " + e.UpdateParam
+	//分片路由，多个分片时影响行数相加
+	defer e.unroute()
+	shards, err := e.route()
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	if len(shards) > 1 {
+		err = e.scatter(shards, func(s *Orm) error {
+			n, err := s.execUpdate()
+			id += n
+			return err
+		})
+	} else {
+		id, err = e.execUpdate()
+	}
+	if err != nil {
+		return 0, err
+	}
 
-	//如果where不为空
-	if e.WhereParam != "" || e.OrWhereParam != "" {
-		e.Prepare += " where " + e.WhereParam + e.OrWhereParam
+	//乐观锁，没有更新到记录说明版本号已变化
+	if version != nil {
+		if id == 0 {
+			return 0, e.setErrorInfo(ErrStaleObject)
+		}
+		increaseVersion(v, version)
 	}
 
-	//limit不为空
-	if e.LimitParam != "" {
-		e.Prepare += "limit " + e.LimitParam
+	//更新后钩子
+	if err := e.callHook(v, afterUpdate); err != nil {
+		return 0, err
 	}
+	return id, nil
+}
 
-	//prepare
-	var stmt *sql.Stmt
-	var err error
-	stmt, err = e.Db.Prepare(e.Prepare)
-	if err != nil {
-		return 0, e.setErrorInfo(err)
+// 拼接并执行update语句，返回影响的行数
+func (e *Orm) execUpdate() (int64, error) {
+	//拼接sql
+	where, args := e.buildWhere(false)
+	e.Prepare = "update " + e.quoteTable() + " set " + e.UpdateParam + where
+
+	//limit不为空
+	if e.LimitParam != "" {
+		e.Prepare += " limit " + e.LimitParam
 	}
 
 	//合并UpdateExec和WhereExec
-	if e.WhereExec != nil {
-		e.AllExec = append(e.UpdateExec, e.WhereExec...)
-	}
+	e.AllExec = append(e.UpdateExec[:len(e.UpdateExec):len(e.UpdateExec)], args...)
 
-	//执行exec,注意这是stmt.Exec
-	result, err := stmt.Exec(e.AllExec...)
+	//执行
+	result, err := e.execStatement(OpUpdate, e.Prepare, e.AllExec)
 	if err != nil {
 		return 0, e.setErrorInfo(err)
 	}
@@ -398,60 +1013,135 @@ func (e *Orm) Update(data ...interface{}) (int64, error) {
 
 // 查询多条，返回值为map切片
 func (e *Orm) Select() ([]map[string]string, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
 
-	//拼接sql
-	e.Prepare = "select * from " + e.GetTable()
+	if err := e.checkLock(); err != nil {
+		return nil, err
+	}
+
+	if err := e.checkTenant(); err != nil {
+		return nil, err
+	}
 
-	//如果where不为空
-	if e.WhereParam != "" || e.OrWhereParam != "" {
-		e.Prepare += " where " + e.WhereParam + e.OrWhereParam
+	//分片路由，多个分片时合并结果
+	defer e.unroute()
+	shards, err := e.route()
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) > 1 {
+		results := make([]map[string]string, 0)
+		err := e.scatter(shards, func(s *Orm) error {
+			rows, err := s.Select()
+			results = append(results, rows...)
+			return err
+		})
+		return results, err
 	}
 
-	e.AllExec = e.WhereExec
+	//拼接sql
+	op := e.buildQuery()
 
 	//query
-	rows, err := e.Db.Query(e.Prepare, e.AllExec...)
+	results, err := e.queryMaps(op, e.Prepare, e.AllExec)
 	if err != nil {
 		return nil, e.setErrorInfo(err)
 	}
 
-	//读出查询出的列字段名
-	column, err := rows.Columns()
+	return results, nil
+}
+
+// 查询并将结果追加到结构体切片
+func (e *Orm) findRows(destSlice reflect.Value) error {
+	//拼接sql
+	op := e.buildQuery()
+
+	//原始单个struct的类型
+	destType := destSlice.Type().Elem()
+	destSchema := parseSchema(destType)
+
+	//query
+	err := e.queryStatement(op, e.Prepare, e.AllExec, func(rows *sql.Rows) (int64, error) {
+		return eachRow(rows, func(columns []string, values [][]byte) error {
+			dest := reflect.New(destType).Elem()
+
+			//遍历一行数据的各个字段
+			for k, v := range values {
+				//遍历结构体
+				for _, field := range destSchema.Fields {
+
+					//struct里没这个key
+					if columns[k] != field.Column {
+						continue
+					}
+
+					//NULL保持零值，指针字段为nil
+					if v == nil {
+						continue
+					}
+
+					//反射赋值
+					if err := e.reflectSet(dest, field.Index, string(v)); err != nil {
+						return err
+					}
+				}
+			}
+			//赋值
+			destSlice.Set(reflect.Append(destSlice, dest))
+			return nil
+		})
+	})
 	if err != nil {
-		return nil, e.setErrorInfo(err)
+		return e.setErrorInfo(err)
 	}
+	return nil
+}
 
-	//values是每个列的值，这里获取到byte里
-	values := make([][]byte, len(column))
-
-	//因为每次查询出来的列是不定长的，用len(column)定住当次查询的长度
-	scans := make([]interface{}, len(column))
+// 设置Prepare和AllExec，设置了Raw()时使用原生sql，返回语句类型
+func (e *Orm) buildQuery() string {
+	if e.RawSql != "" {
+		e.Prepare, e.AllExec = e.RawSql, e.RawExec
+		return OpQuery
+	}
+	e.Prepare, e.AllExec = e.buildSelect()
+	return OpSelect
+}
 
-	for i := range values {
-		scans[i] = &values[i]
+// 拼接完整的select语句，返回语句和参数
+func (e *Orm) buildSelect() (string, []interface{}) {
+	field := e.FieldParam
+	if field == "" {
+		field = "*"
 	}
+	where, args := e.buildWhere(true)
+	sqlStr := "select " + field + " from " + e.quoteTable() + where
 
-	results := make([]map[string]string, 0)
-	for rows.Next() {
-		if err := rows.Scan(scans...); err != nil {
-			//query.Scan查询出来的不定长值放到scans[i] = &values[i],也就是每行都放在values里
-			return nil, e.setErrorInfo(err)
-		}
+	//group不为空
+	if e.GroupParam != "" {
+		sqlStr += " group by " + e.GroupParam
+	}
 
-		//每行数据
-		row := make(map[string]string)
+	//having不为空
+	if e.HavingParam != "" {
+		sqlStr += " having " + e.HavingParam
+	}
 
-		//循环values数据，通过相同的下标，取column里面对应的列名，生成1个新的map
-		for k, v := range values {
-			key := column[k]
-			row[key] = string(v)
-		}
+	//order不为空
+	if e.OrderParam != "" {
+		sqlStr += " order by " + e.OrderParam
+	}
 
-		//添加到map切片中
-		results = append(results, row)
+	//limit不为空
+	if e.LimitParam != "" {
+		sqlStr += " limit " + e.LimitParam
 	}
 
-	return results, nil
+	//加锁
+	sqlStr += e.buildLock()
+
+	return sqlStr, args
 }
 
 // 查询1条
@@ -473,6 +1163,9 @@ func (e *Orm) SelectOne() (map[string]string, error) {
 
 // 查询多条，返回值为struct切片
 func (e *Orm) Find(result interface{}) error {
+	if e.Err != nil {
+		return e.Err
+	}
 
 	if reflect.ValueOf(result).Kind() != reflect.Ptr {
 		return e.setErrorInfo(errors.New("参数请传指针变量！"))
@@ -482,80 +1175,53 @@ func (e *Orm) Find(result interface{}) error {
 		return e.setErrorInfo(errors.New("参数不能是空指针！"))
 	}
 
-	//拼接sql
-	e.Prepare = "select * from " + e.GetTable()
-
-	e.AllExec = e.WhereExec
-
-	//query
-	rows, err := e.Db.Query(e.Prepare, e.AllExec...)
-	if err != nil {
-		return e.setErrorInfo(err)
+	if err := e.checkLock(); err != nil {
+		return err
 	}
 
-	//读出查询出的列字段名
-	column, err := rows.Columns()
-	if err != nil {
-		return e.setErrorInfo(err)
+	//未设置模型时，以结构体类型作为模型
+	if e.ModelType == nil {
+		if e.Model(result); e.Err != nil {
+			return e.Err
+		}
 	}
 
-	//values是每个列的值，这里获取到byte里
-	values := make([][]byte, len(column))
-
-	//因为每次查询出来的列是不定长的，用len(column)定住当次查询的长度
-	scans := make([]interface{}, len(column))
-
 	//原始struct的切片值
 	destSlice := reflect.ValueOf(result).Elem()
 
-	//原始单个struct的类型
-	destType := destSlice.Type().Elem()
-
-	for i := range values {
-		scans[i] = &values[i]
+	if err := e.checkTenant(); err != nil {
+		return err
 	}
 
-	//循环遍历
-	for rows.Next() {
-
-		dest := reflect.New(destType).Elem()
+	//分片路由，多个分片时依次查询并追加到同一个切片
+	defer e.unroute()
+	shards, err := e.route()
+	if err != nil {
+		return err
+	}
+	if len(shards) > 1 {
+		err = e.scatter(shards, func(s *Orm) error {
+			return s.findRows(destSlice)
+		})
+	} else {
+		err = e.findRows(destSlice)
+	}
+	if err != nil {
+		return err
+	}
 
-		if err := rows.Scan(scans...); err != nil {
-			//query.Scan查询出来的不定长值放到scans[i] = &values[i],也就是每行都放在values里
-			return e.setErrorInfo(err)
+	//预加载关联
+	if len(e.PreloadParam) > 0 {
+		if err := e.preload(destSlice); err != nil {
+			return err
 		}
+	}
 
-		//遍历一行数据的各个字段
-		for k, v := range values {
-			//每行数据是放在values里面，现在把它挪到row里
-			key := column[k]
-			value := string(v)
-
-			//遍历结构体
-			for i := 0; i < destType.NumField(); i++ {
-
-				//看下是否有sql别名
-				sqlTag := destType.Field(i).Tag.Get("sql")
-				var fieldName string
-				if sqlTag != "" {
-					fieldName = strings.Split(sqlTag, ",")[0]
-				} else {
-					fieldName = destType.Field(i).Name
-				}
-
-				//struct里没这个key
-				if key != fieldName {
-					continue
-				}
-
-				//反射赋值
-				if err := e.reflectSet(dest, i, value); err != nil {
-					return err
-				}
-			}
+	//查询后钩子
+	for i := 0; i < destSlice.Len(); i++ {
+		if err := e.callHook(destSlice.Index(i), afterFind); err != nil {
+			return err
 		}
-		//赋值
-		destSlice.Set(reflect.Append(destSlice, dest))
 	}
 
 	return nil
@@ -563,39 +1229,61 @@ func (e *Orm) Find(result interface{}) error {
 
 // 反射赋值
 func (e *Orm) reflectSet(dest reflect.Value, i int, value string) error {
-	switch dest.Field(i).Kind() {
+	return e.setValue(dest.Field(i), value)
+}
+
+// 按字段类型解析字符串并赋值，指针字段分配新值，time.Time按时间字符串解析
+func (e *Orm) setValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(field.Type().Elem())
+		if err := e.setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+	case reflect.Struct:
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			//驱动开启parseTime时为RFC3339格式
+			res, err := time.Parse(timeFormat, value)
+			if err != nil {
+				if res, err = time.Parse(time.RFC3339Nano, value); err != nil {
+					return e.setErrorInfo(err)
+				}
+			}
+			field.Set(reflect.ValueOf(res))
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		res, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
 			return e.setErrorInfo(err)
 		}
-		dest.Field(i).SetInt(res)
+		field.SetInt(res)
 	case reflect.String:
-		dest.Field(i).SetString(value)
+		field.SetString(value)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		res, err := strconv.ParseUint(value, 10, 64)
 		if err != nil {
 			return e.setErrorInfo(err)
 		}
-		dest.Field(i).SetUint(res)
+		field.SetUint(res)
 	case reflect.Float32:
 		res, err := strconv.ParseFloat(value, 32)
 		if err != nil {
 			return e.setErrorInfo(err)
 		}
-		dest.Field(i).SetFloat(res)
+		field.SetFloat(res)
 	case reflect.Float64:
 		res, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return e.setErrorInfo(err)
 		}
-		dest.Field(i).SetFloat(res)
+		field.SetFloat(res)
 	case reflect.Bool:
 		res, err := strconv.ParseBool(value)
 		if err != nil {
 			return e.setErrorInfo(err)
 		}
-		dest.Field(i).SetBool(res)
+		field.SetBool(res)
 	}
 	return nil
 }
@@ -616,15 +1304,37 @@ func (e *Orm) FindOne(result interface{}) error {
 
 	//判断返回值长度
 	if destSlice.Len() == 0 {
-		return e.setErrorInfo(errors.New("NOT FOUND"))
+		return e.setErrorInfo(ErrRecordNotFound)
 	}
 
 	//取切片里的第0个数据，并复制给原始值结构体指针
 	dest.Set(destSlice.Index(0))
 	return nil
 }
-func (e *Orm) Field(field string) *Orm {
-	e.FieldParam = field
+
+// 设置查询字段，参数为逗号分隔的字段名或orm.Raw表达式，如Field("uid,username")、Field("uid", orm.Raw("count(*) as num"))
+func (e *Orm) Field(field ...interface{}) *Orm {
+	var fields []string
+	for _, f := range field {
+		//字符串按逗号拆分为多个字段
+		items := []interface{}{f}
+		if s, ok := f.(string); ok {
+			items = items[:0]
+			for _, item := range strings.Split(s, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+		for _, item := range items {
+			quoted, err := e.quoteField(item)
+			if err != nil {
+				return e.addError(err)
+			}
+			fields = append(fields, quoted)
+		}
+	}
+	if len(fields) != 0 {
+		e.FieldParam = strings.Join(fields, ",")
+	}
 	return e
 }
 
@@ -635,42 +1345,95 @@ func (e *Orm) Limit(limit ...int64) *Orm {
 	} else if len(limit) == 2 {
 		e.LimitParam = strconv.Itoa(int(limit[0])) + "," + strconv.Itoa(int(limit[1]))
 	} else {
-		panic("参数个数错误")
+		return e.addError(errors.New("limit参数个数错误"))
 	}
 	return e
 }
 
 // 聚合查询
 func (e *Orm) aggregateQuery(name, param string) (interface{}, error) {
+	if e.Err != nil {
+		return nil, e.Err
+	}
 
-	//拼接sql
-	e.Prepare = "select " + name + "(" + param + ") as cnt from " + e.GetTable()
+	if err := e.checkTenant(); err != nil {
+		return nil, err
+	}
+
+	//分片路由，多个分片时只支持count，结果相加
+	defer e.unroute()
+	shards, err := e.route()
+	if err != nil {
+		return nil, err
+	}
+	if len(shards) > 1 {
+		if name != "count" {
+			return nil, e.setErrorInfo(errors.New(name + "不支持跨分片查询"))
+		}
+		var total int64
+		err := e.scatter(shards, func(s *Orm) error {
+			n, err := s.Count()
+			total += n
+			return err
+		})
+		return total, err
+	}
 
-	//如果where不为空
-	if e.WhereParam != "" || e.OrWhereParam != "" {
-		e.Prepare += " where " + e.WhereParam + e.OrWhereParam
+	//聚合的字段名，count(*)除外
+	if param != "*" {
+		quoted, err := e.quoteColumn(param)
+		if err != nil {
+			return nil, e.setErrorInfo(err)
+		}
+		param = quoted
 	}
 
+	//拼接sql
+	where, args := e.buildWhere(true)
+	e.Prepare = "select " + name + "(" + param + ") as cnt from " + e.quoteTable() + where
+
 	//limit不为空
 	if e.LimitParam != "" {
 		e.Prepare += " limit " + e.LimitParam
 	}
 
-	e.AllExec = e.WhereExec
-
-	//生成sql
-	e.generateSql()
+	e.AllExec = args
 
 	//执行绑定
 	var cnt interface{}
 
-	//queryRows
-	err := e.Db.QueryRow(e.Prepare, e.AllExec...).Scan(&cnt)
+	//query，只取第一行
+	err = e.queryStatement(OpSelect, e.Prepare, e.AllExec, func(rows *sql.Rows) (int64, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return 0, err
+			}
+			return 0, sql.ErrNoRows
+		}
+		return 1, rows.Scan(&cnt)
+	})
 	if err != nil {
 		return nil, e.setErrorInfo(err)
 	}
 
-	return cnt, err
+	return cnt, nil
+}
+
+// 聚合结果转为字符串，NULL（如空表的max）返回"0"
+func aggregateString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "0"
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
 }
 
 // 总数
@@ -679,14 +1442,18 @@ func (e *Orm) Count() (int64, error) {
 	if err != nil {
 		return 0, e.setErrorInfo(err)
 	}
-	return count.(int64), err
+	n, err := strconv.ParseInt(aggregateString(count), 10, 64)
+	if err != nil {
+		return 0, e.setErrorInfo(err)
+	}
+	return n, nil
 }
 func (e *Orm) Max(param string) (string, error) {
 	max, err := e.aggregateQuery("max", param)
 	if err != nil {
 		return "0", e.setErrorInfo(err)
 	}
-	return string(max.([]byte)), nil
+	return aggregateString(max), nil
 }
 
 // 最小值
@@ -696,7 +1463,7 @@ func (e *Orm) Min(param string) (string, error) {
 		return "0", e.setErrorInfo(err)
 	}
 
-	return string(min.([]byte)), nil
+	return aggregateString(min), nil
 }
 
 // 平均值
@@ -706,7 +1473,7 @@ func (e *Orm) Avg(param string) (string, error) {
 		return "0", e.setErrorInfo(err)
 	}
 
-	return string(avg.([]byte)), nil
+	return aggregateString(avg), nil
 }
 
 // 总和
@@ -715,43 +1482,55 @@ func (e *Orm) Sum(param string) (string, error) {
 	if err != nil {
 		return "0", e.setErrorInfo(err)
 	}
-	return string(sum.([]byte)), nil
+	return aggregateString(sum), nil
 }
 
-// order排序
-func (e *Orm) Order(order ...string) *Orm {
+// order排序，字段名可以是orm.Raw表达式，如Order("uid", "asc", orm.Raw("field(status,2,1)"), "desc")
+func (e *Orm) Order(order ...interface{}) *Orm {
 	orderLen := len(order)
 	if orderLen%2 != 0 {
-		panic("order by参数错误，请保证个数为偶数个")
+		return e.addError(errors.New("order by参数错误，请保证个数为偶数个"))
 	}
 
 	//排序的个数
 	orderNum := orderLen / 2
 
-	//多次调用的情况
-	if e.OrderParam != "" {
-		e.OrderParam += ","
-	}
-
+	//先校验，避免拼接一半
+	orders := make([]string, orderNum)
 	for i := 0; i < orderNum; i++ {
-		keyString := strings.ToLower(order[i*2+1])
-		if keyString != "desc" && keyString != "asc" {
-			panic("排序关键字为：desc和asc")
+		column, err := e.quoteColumn(order[i*2])
+		if err != nil {
+			return e.addError(err)
 		}
-		if i < orderNum-1 {
-			e.OrderParam += order[i*2] + " " + order[i*2+1] + ","
-		} else {
-			e.OrderParam += order[i*2] + " " + order[i*2+1]
+		keyString, _ := order[i*2+1].(string)
+		keyString = strings.ToLower(keyString)
+		if keyString != "desc" && keyString != "asc" {
+			return e.addError(errors.New("排序关键字为：desc和asc"))
 		}
+		orders[i] = column + " " + keyString
+	}
+
+	//多次调用的情况
+	if e.OrderParam != "" {
+		e.OrderParam += ","
 	}
+	e.OrderParam += strings.Join(orders, ",")
 
 	return e
 }
 
-// group分组
-func (e *Orm) Group(group ...string) *Orm {
-	if len(group) != 0 {
-		e.GroupParam = strings.Join(group, ",")
+// group分组，字段名可以是orm.Raw表达式
+func (e *Orm) Group(group ...interface{}) *Orm {
+	groups := make([]string, len(group))
+	for i, g := range group {
+		column, err := e.quoteColumn(g)
+		if err != nil {
+			return e.addError(err)
+		}
+		groups[i] = column
+	}
+	if len(groups) != 0 {
+		e.GroupParam = strings.Join(groups, ",")
 	}
 	return e
 }
@@ -768,69 +1547,72 @@ func (e *Orm) Having(having ...interface{}) *Orm {
 	} else if len(having) == 3 {
 		dataType = 3
 	} else {
-		panic("having个数错误")
+		return e.addError(errors.New("having个数错误"))
 	}
 
-	//多次调用判断
-	if e.HavingParam != "" {
-		e.HavingParam += "and ("
-	} else {
-		e.HavingParam += "("
+	//校验字段名和操作符
+	var column, operator string
+	if dataType >= 2 {
+		var err error
+		if column, err = e.quoteColumn(having[0]); err != nil {
+			return e.addError(err)
+		}
+	}
+	if dataType == 3 {
+		op, ok := having[1].(string)
+		if !ok {
+			return e.addError(errors.New("having的操作符必须是字符串"))
+		}
+		var err error
+		if operator, err = checkOperator(op); err != nil {
+			return e.addError(err)
+		}
 	}
 
+	var condition string
+
 	//如果是结构体
 	if dataType == 1 {
-		t := reflect.TypeOf(having[0])
-		v := reflect.ValueOf(having[0])
+		v := indirectValue(reflect.ValueOf(having[0]))
+		if v.Kind() != reflect.Struct {
+			return e.addError(errors.New("单个参数时having条件必须是结构体"))
+		}
 
 		var fieldNameArray []string
-		for i := 0; i < t.NumField(); i++ {
-
-			//小写开头，无法反射，跳过
-			if !v.Field(i).CanInterface() {
-				continue
-			}
-
-			//解析tag，找出真实的sql字段名
-			sqlTag := t.Field(i).Tag.Get("sql")
-			if sqlTag != "" {
-				fieldNameArray = append(fieldNameArray, strings.Split(sqlTag, ",")[0]+"=?")
-			} else {
-				fieldNameArray = append(fieldNameArray, t.Field(i).Name+"=?")
-			}
-
-			e.WhereExec = append(e.WhereExec, v.Field(i).Interface())
+		for _, field := range parseSchema(v.Type()).Fields {
+			fieldNameArray = append(fieldNameArray, e.getDialect().Quote(field.Column)+"=?")
+			e.WhereExec = append(e.WhereExec, v.Field(field.Index).Interface())
 		}
-		e.HavingParam += strings.Join(fieldNameArray, " and ") + ") "
+		condition = strings.Join(fieldNameArray, " and ")
 
 	} else if dataType == 2 {
 		//直接=的情况
-		e.HavingParam += having[0].(string) + "=?) "
+		condition = column + "=?"
 		e.WhereExec = append(e.WhereExec, having[1])
 	} else if dataType == 3 {
 		//3个参数的情况
-		e.HavingParam += having[0].(string) + " " + having[1].(string) + " ?) "
-		e.WhereExec = append(e.WhereExec, having[2])
+		var args []interface{}
+		var err error
+		if condition, args, err = buildCondition(column, operator, having[2]); err != nil {
+			return e.addError(err)
+		}
+		e.WhereExec = append(e.WhereExec, args...)
+	}
+
+	//多次调用判断
+	if e.HavingParam != "" {
+		e.HavingParam += "and ("
+	} else {
+		e.HavingParam += "("
 	}
+	e.HavingParam += condition + ") "
 
 	return e
 }
 
-// 生成完成的sql语句
+// 生成完整的sql语句，每次执行后调用，通过GetLastSql()获取
 func (e *Orm) generateSql() {
-	e.Sql = e.Prepare
-	for _, i2 := range e.AllExec {
-		switch i2.(type) {
-		case int:
-			e.Sql = strings.Replace(e.Sql, "?", strconv.Itoa(i2.(int)), 1)
-		case int64:
-			e.Sql = strings.Replace(e.Sql, "?", strconv.FormatInt(i2.(int64), 10), 1)
-		case bool:
-			e.Sql = strings.Replace(e.Sql, "?", strconv.FormatBool(i2.(bool)), 1)
-		default:
-			e.Sql = strings.Replace(e.Sql, "?", "'"+i2.(string)+"'", 1)
-		}
-	}
+	e.Sql = interpolate(e.getDialect(), e.Prepare, e.AllExec)
 }
 
 // 获取最后执行生成的sql
@@ -838,63 +1620,84 @@ func (e *Orm) GetLastSql() string {
 	return e.Sql
 }
 
-// 直接执行增删改sql
-func (e *Orm) Exec(sql string) (id int64, err error) {
-	result, err := e.Db.Exec(sql)
-	e.Sql = sql
+// 原生查询sql，支持命名参数，与Table()一样重置查询条件，之后调用Find/FindOne映射到结构体，Select/SelectOne返回map，Scan读取单行的列值
+func (e *Orm) Raw(query string, args ...interface{}) *Orm {
+	e.resetOrm()
+	query, args, err := e.bindNamed(query, args)
 	if err != nil {
-		return 0, e.setErrorInfo(err)
+		return e.addError(err)
 	}
+	e.RawSql = query
+	e.RawExec = args
+	return e
+}
 
-	//区分是insert还是其他(update,delete)
-	if strings.Contains(sql, "insert") {
-		lastInsertId, _ := result.LastInsertId()
-		return lastInsertId, nil
-	} else {
-		rowsAffected, _ := result.RowsAffected()
-		return rowsAffected, nil
+// 读取查询结果第一行的各列到dest，如Raw("select count(*),max(uid) from user").Scan(&num, &maxUid)，没有记录时返回ErrRecordNotFound
+func (e *Orm) Scan(dest ...interface{}) error {
+	if e.Err != nil {
+		return e.Err
 	}
-}
 
-// 直接执行查sql
-func (e *Orm) Query(sql string) ([]map[string]string, error) {
-	rows, err := e.Db.Query(sql)
-	e.Sql = sql
-	if err != nil {
-		return nil, e.setErrorInfo(err)
+	if err := e.checkLock(); err != nil {
+		return err
 	}
 
-	//读出查询出的列字段名
-	column, err := rows.Columns()
-	if err != nil {
-		return nil, e.setErrorInfo(err)
+	if err := e.checkTenant(); err != nil {
+		return err
 	}
 
-	//values是每个列的值，这里获取到byte里
-	values := make([][]byte, len(column))
+	//只读取一行，不支持跨分片
+	defer e.unroute()
+	if shards, err := e.route(); err != nil {
+		return err
+	} else if len(shards) > 1 {
+		return e.setErrorInfo(errors.New("Scan不支持跨分片查询"))
+	}
 
-	//因为每次查询出来的列是不定长的，用len(column)定住当次查询的长度
-	scans := make([]interface{}, len(column))
+	op := e.buildQuery()
+	err := e.queryStatement(op, e.Prepare, e.AllExec, func(rows *sql.Rows) (int64, error) {
+		if !rows.Next() {
+			if err := rows.Err(); err != nil {
+				return 0, err
+			}
+			return 0, ErrRecordNotFound
+		}
+		return 1, rows.Scan(dest...)
+	})
+	if err != nil {
+		return e.setErrorInfo(err)
+	}
+	return nil
+}
 
-	for i := range values {
-		scans[i] = &values[i]
+// 直接执行增删改sql
+func (e *Orm) Exec(query string, args ...interface{}) (ExecResult, error) {
+	var res ExecResult
+	query, args, err := e.bindNamed(query, args)
+	if err != nil {
+		return res, e.setErrorInfo(err)
+	}
+	result, err := e.execStatement(OpExec, query, args)
+	if err != nil {
+		return res, e.setErrorInfo(err)
 	}
 
-	//最后得到的map
-	results := make([]map[string]string, 0)
-	for rows.Next() {
-		if err := rows.Scan(scans...); err != nil {
-			//query.Scan查询出来的不定长值放到scans[i] = &values[i],也就是每行都放在values里
-			return nil, e.setErrorInfo(err)
-		}
+	//不支持的驱动返回错误，忽略
+	res.LastInsertId, _ = result.LastInsertId()
+	res.RowsAffected, _ = result.RowsAffected()
+	return res, nil
+}
 
-		row := make(map[string]string) //每行数据
-		for k, v := range values {
-			//每行数据是放在values里面，现在把它挪到row里
-			key := column[k]
-			row[key] = string(v)
-		}
-		results = append(results, row)
+// 直接执行查sql
+func (e *Orm) Query(query string, args ...interface{}) ([]map[string]string, error) {
+	query, args, err := e.bindNamed(query, args)
+	if err != nil {
+		return nil, e.setErrorInfo(err)
+	}
+
+	results, err := e.queryMaps(OpQuery, query, args)
+	if err != nil {
+		return nil, e.setErrorInfo(err)
 	}
 
 	return results, nil
@@ -902,9 +1705,14 @@ func (e *Orm) Query(sql string) ([]map[string]string, error) {
 
 // 开启事务
 func (e *Orm) Begin() error {
+	return e.begin(e.Db)
+}
+
+// 在db上开启事务
+func (e *Orm) begin(db *sql.DB) error {
 
 	//调用原生的开启事务方法
-	tx, err := e.Db.Begin()
+	tx, err := db.BeginTx(e.context(), nil)
 	if err != nil {
 		return e.setErrorInfo(err)
 	}
@@ -924,3 +1732,23 @@ func (e *Orm) Commit() error {
 	e.TransStatus = 0
 	return e.Tx.Commit()
 }
+
+// 在事务中执行fn，已开启事务时直接使用当前事务，否则自动开启并根据fn的结果提交或回滚
+func (e *Orm) transaction(fn func() error) error {
+	if e.TransStatus == 1 {
+		return fn()
+	}
+
+	//已路由到分片时在分片所在的库上开启
+	if err := e.begin(e.getDb()); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		_ = e.Rollback()
+		return err
+	}
+	if err := e.Commit(); err != nil {
+		return e.setErrorInfo(err)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type batchUser struct {
+	Uid  int64  `sql:"uid,auto_increment"`
+	Name string `sql:"name"`
+}
+
+// 按插入的行数返回连续的自增ID，第fail次执行返回错误
+func fakeAutoIncrement(c *fakeConnector, columns int, fail int) {
+	next, n := int64(1), 0
+	c.exec = func(q fakeQuery) (driver.Result, error) {
+		if n++; n == fail {
+			return nil, errors.New("fake: insert failed")
+		}
+		rows := int64(len(q.args) / columns)
+		id := next
+		next += rows
+		return fakeResult{id: id, affected: rows}, nil
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeAutoIncrement(c, 1, 0)
+	e := newFakeOrm(t, c)
+
+	users := []batchUser{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	res, err := e.Table("user").InsertBatch(users, 2)
+	if err != nil {
+		t.Fatalf("InsertBatch() error = %v", err)
+	}
+	if want := (BatchResult{RowsAffected: 5, FirstInsertId: 1, LastInsertId: 5}); res != want {
+		t.Fatalf("InsertBatch() = %+v, want %+v", res, want)
+	}
+
+	executed := c.executed()
+	if len(executed) != 3 {
+		t.Fatalf("executed %d statements, want 3", len(executed))
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`) values (?)", "e")
+	if q := executed[0]; q.query != "insert into `user` (`name`) values (?),(?)" || len(q.args) != 2 {
+		t.Fatalf("executed %q %v", q.query, q.args)
+	}
+	if c.commits != 1 || c.rollbacks != 0 {
+		t.Fatalf("commits, rollbacks = %d, %d, want 1, 0", c.commits, c.rollbacks)
+	}
+}
+
+func TestInsertBatchWithoutAutoIncrement(t *testing.T) {
+	//驱动返回的ID不是结构体的自增ID
+	c := &fakeConnector{failAt: -1}
+	fakeAutoIncrement(c, 2, 0)
+	e := newFakeOrm(t, c)
+
+	res, err := e.Table("user").InsertBatch([]fakeUser{{Uid: 1}, {Uid: 2}, {Uid: 3}}, 2)
+	if err != nil {
+		t.Fatalf("InsertBatch() error = %v", err)
+	}
+	if want := (BatchResult{RowsAffected: 3}); res != want {
+		t.Fatalf("InsertBatch() = %+v, want %+v", res, want)
+	}
+
+	//没有返回自增ID
+	c = &fakeConnector{failAt: -1, affected: 2}
+	e = newFakeOrm(t, c)
+	if res, err := e.Table("user").InsertBatch([]batchUser{{Name: "a"}, {Name: "b"}}, 2); err != nil || res != (BatchResult{RowsAffected: 2}) {
+		t.Fatalf("InsertBatch() = %+v, %v", res, err)
+	}
+}
+
+func TestInsertBatchPlaceholders(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeAutoIncrement(c, 2, 0)
+	e := newFakeOrm(t, c)
+
+	//每行2个占位符，超过上限时每批最多maxPlaceholders/2条
+	users := make([]fakeUser, maxPlaceholders/2+3)
+	res, err := e.Table("user").InsertBatch(users, len(users))
+	if err != nil {
+		t.Fatalf("InsertBatch() error = %v", err)
+	}
+	if res.RowsAffected != int64(len(users)) {
+		t.Fatalf("RowsAffected = %d, want %d", res.RowsAffected, len(users))
+	}
+	executed := c.executed()
+	if len(executed) != 2 {
+		t.Fatalf("executed %d statements, want 2", len(executed))
+	}
+	if n := len(executed[0].args); n != maxPlaceholders/2*2 {
+		t.Fatalf("first statement has %d placeholders, want %d", n, maxPlaceholders/2*2)
+	}
+	if n := strings.Count(executed[1].query, "(?,?)"); n != 3 {
+		t.Fatalf("second statement inserts %d rows, want 3", n)
+	}
+}
+
+func TestInsertBatchRollback(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeAutoIncrement(c, 1, 2)
+	e := newFakeOrm(t, c)
+
+	//第二批失败时回滚第一批
+	res, err := e.Table("user").InsertBatch([]batchUser{{Name: "a"}, {Name: "b"}, {Name: "c"}}, 2)
+	if err == nil {
+		t.Fatal("InsertBatch() error = nil")
+	}
+	if res != (BatchResult{}) {
+		t.Fatalf("InsertBatch() = %+v, want zero result", res)
+	}
+	if len(c.executed()) != 2 || c.commits != 0 || c.rollbacks != 1 {
+		t.Fatalf("executed, commits, rollbacks = %d, %d, %d, want 2, 0, 1", len(c.executed()), c.commits, c.rollbacks)
+	}
+	if e.TransStatus != 0 {
+		t.Fatal("transaction not finished after rollback")
+	}
+}
+
+func TestWhereEmptyIn(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	if _, err := e.Table("user").Where("uid", "in", []int{}).Select(); err == nil {
+		t.Fatal("Select() with empty in error = nil")
+	}
+	if _, err := e.Table("user").Where("uid", "not in", []int{}).Delete(); err == nil {
+		t.Fatal("Delete() with empty not in error = nil")
+	}
+	if _, err := e.Table("user").Where("uid", 1).Having("uid", "in", []int{}).Select(); err == nil {
+		t.Fatal("Select() with empty having in error = nil")
+	}
+	assertNoQuery(t, c)
+
+	if _, err := e.Table("user").Where("uid", "in", []int{1, 2}).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `user` where (`uid` in (?,?))", int64(1), int64(2))
+}
+
+func TestInsertEmpty(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//空切片不插入
+	if id, err := e.Table("user").Insert([]fakeUser{}); err != nil || id != 0 {
+		t.Fatalf("Insert() = %d, %v, want 0, nil", id, err)
+	}
+	if id, err := e.Table("user").Replace(&[0]fakeUser{}); err != nil || id != 0 {
+		t.Fatalf("Replace() = %d, %v, want 0, nil", id, err)
+	}
+	if res, err := e.Table("user").InsertBatch([]fakeUser{}, 10); err != nil || res != (BatchResult{}) {
+		t.Fatalf("InsertBatch() = %+v, %v", res, err)
+	}
+	assertNoQuery(t, c)
+}
+
+func TestRawExec(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	c.exec = func(fakeQuery) (driver.Result, error) { return fakeResult{id: 7, affected: 2}, nil }
+	e := newFakeOrm(t, c)
+
+	//不按语句类型区分，同时返回自增ID和影响的行数
+	for _, query := range []string{"insert into user (name) values (?),(?)", "update user set name=? where uid in (?)"} {
+		res, err := e.Exec(query, "a", 1)
+		if err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+		if res != (ExecResult{LastInsertId: 7, RowsAffected: 2}) {
+			t.Fatalf("Exec() = %+v", res)
+		}
+		assertLastQuery(t, c, query, "a", int64(1))
+	}
+
+	if _, err := e.Exec("update user set name=:name where uid=:uid", map[string]interface{}{"name": "b", "uid": 2}); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	assertLastQuery(t, c, "update user set name=? where uid=?", "b", int64(2))
+}
+
+func TestRawQuery(t *testing.T) {
+	c := &fakeConnector{columns: []string{"uid", "name"}, data: [][]string{{"1", "a"}, {"2", "b"}}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	rows, err := e.Query("select uid,name from user where uid > ?", 0)
+	if err != nil || len(rows) != 2 || rows[1]["name"] != "b" {
+		t.Fatalf("Query() = %v, %v", rows, err)
+	}
+	assertLastQuery(t, c, "select uid,name from user where uid > ?", int64(0))
+
+	//与Find相同的结构体映射
+	var users []fakeUser
+	if err := e.Raw("select uid,name from user where name like ?", "%a%").Find(&users); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if want := []fakeUser{{1, "a"}, {2, "b"}}; !reflect.DeepEqual(users, want) {
+		t.Fatalf("Find() = %+v, want %+v", users, want)
+	}
+	assertLastQuery(t, c, "select uid,name from user where name like ?", "%a%")
+
+	var uid int64
+	var name string
+	if err := e.Raw("select uid,name from user where uid=:uid", map[string]interface{}{"uid": 1}).Scan(&uid, &name); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if uid != 1 || name != "a" {
+		t.Fatalf("Scan() = %d, %s", uid, name)
+	}
+	assertLastQuery(t, c, "select uid,name from user where uid=?", int64(1))
+
+	c.data = nil
+	if err := e.Raw("select uid from user where uid=?", 3).Scan(&uid); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("Scan() error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
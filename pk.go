@@ -0,0 +1,104 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+)
+
+// 按主键查询单条，联合主键按tag定义的顺序传入多个值，如Get(&user, 1)
+func (e *Orm) Get(result interface{}, keys ...interface{}) error {
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return e.setErrorInfo(errors.New("参数请传结构体指针！"))
+	}
+
+	pks, err := e.primaryKeys(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	if len(keys) != len(pks) {
+		return e.setErrorInfo(errors.New("主键值个数与主键字段个数不一致"))
+	}
+
+	for i, pk := range pks {
+		e.Where(pk.Column, keys[i])
+	}
+	return e.FindOne(result)
+}
+
+// 按主键更新，除主键外的字段全部更新
+func (e *Orm) UpdateByPK(data interface{}) (int64, error) {
+	v := indirectValue(reflect.ValueOf(data))
+	if err := e.wherePrimaryKey(v); err != nil {
+		return 0, err
+	}
+	return e.Update(data)
+}
+
+// 按主键删除
+func (e *Orm) DeleteByPK(data interface{}) (int64, error) {
+	v := indirectValue(reflect.ValueOf(data))
+	if err := e.Model(data).wherePrimaryKey(v); err != nil {
+		return 0, err
+	}
+	return e.Delete()
+}
+
+// 保存，主键对应的记录存在则更新，否则插入，传入指针时回写自增ID
+func (e *Orm) Save(data interface{}) (int64, error) {
+	v := indirectValue(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return 0, e.setErrorInfo(errors.New("保存的数据格式不正确，格式为: struct"))
+	}
+
+	//自增主键为空，直接插入
+	s := parseSchema(v.Type())
+	if s.AutoIncrement != nil && s.AutoIncrement.PrimaryKey && v.Field(s.AutoIncrement.Index).IsZero() {
+		return e.Insert(data)
+	}
+
+	//判断记录是否存在，软删除的记录同样视为存在，避免主键冲突
+	if err := e.wherePrimaryKey(v); err != nil {
+		return 0, err
+	}
+	count, err := e.Unscoped().UsePrimary().Count()
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		//自增主键已有值时按该值插入
+		e.IsInsertKey = true
+		return e.Insert(data)
+	}
+
+	//Count已按主键拼接where，直接更新
+	return e.Update(data)
+}
+
+// 获取结构体的主键字段
+func (e *Orm) primaryKeys(t reflect.Type) ([]*schemaField, error) {
+	pks := parseSchema(t).PrimaryKeys
+	if len(pks) == 0 {
+		return nil, e.setErrorInfo(errors.New("结构体未定义主键，请在tag中标记，如`sql:\"id,pk\"`"))
+	}
+	return pks, nil
+}
+
+// 按结构体的主键值拼接where
+func (e *Orm) wherePrimaryKey(v reflect.Value) error {
+	if v.Kind() != reflect.Struct {
+		return e.setErrorInfo(errors.New("参数必须是结构体或结构体指针"))
+	}
+
+	pks, err := e.primaryKeys(v.Type())
+	if err != nil {
+		return err
+	}
+	if e.ModelType == nil {
+		e.ModelType = v.Type()
+	}
+	for _, pk := range pks {
+		e.Where(pk.Column, v.Field(pk.Index).Interface())
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package orm
+
+import "testing"
+
+type pkUser struct {
+	Uid  int64  `sql:"uid,auto_increment"`
+	Name string `sql:"name"`
+}
+
+func TestSave(t *testing.T) {
+	c := &fakeConnector{columns: []string{"cnt"}, data: [][]string{{"0"}}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//自增主键为空时直接插入
+	if _, err := e.Table("user").Save(&pkUser{Name: "a"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`) values (?)", "a")
+
+	//自增主键有值但记录不存在，按该主键插入
+	if _, err := e.Table("user").Save(&pkUser{Uid: 5, Name: "b"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	executed := c.executed()
+	if q := executed[len(executed)-2]; q.query != "select count(*) as cnt from `user` where (`uid`=?) " {
+		t.Fatalf("executed %q", q.query)
+	}
+	assertLastQuery(t, c, "insert into `user` (`uid`,`name`) values (?,?)", int64(5), "b")
+
+	//记录存在时更新
+	c.data = [][]string{{"1"}}
+	if _, err := e.Table("user").Save(&pkUser{Uid: 5, Name: "c"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=? where (`uid`=?)", "c", int64(5))
+
+	//之后的插入不包含自增字段
+	if _, err := e.Table("user").Insert(&pkUser{Uid: 6, Name: "d"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`) values (?)", "d")
+}
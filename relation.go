@@ -0,0 +1,240 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// 预加载关联，参数为结构体中的关联字段名，如Preload("Orders")，Find/FindOne时每个关联额外执行一次in查询
+func (e *Orm) Preload(names ...string) *Orm {
+	e.PreloadParam = append(e.PreloadParam, names...)
+	return e
+}
+
+// 查询并填充关联数据，destSlice为已查出的结构体切片
+func (e *Orm) preload(destSlice reflect.Value) error {
+	if destSlice.Len() == 0 {
+		return nil
+	}
+
+	s := parseSchema(destSlice.Type().Elem())
+	for _, name := range e.PreloadParam {
+		rel, ok := s.Relations[name]
+		if !ok {
+			return e.setErrorInfo(errors.New("未定义的关联：" + name))
+		}
+		if rel.Table == "" || rel.ForeignKey == "" {
+			return e.setErrorInfo(errors.New("关联" + name + "必须设置table和foreign_key"))
+		}
+
+		var err error
+		switch rel.Kind {
+		case "has_one", "has_many":
+			err = e.preloadHas(destSlice, s, rel)
+		case "belongs_to":
+			err = e.preloadBelongsTo(destSlice, rel)
+		case "many_to_many":
+			err = e.preloadManyToMany(destSlice, s, rel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// has_one/has_many：关联表的foreign_key引用当前表的references（默认主键）
+func (e *Orm) preloadHas(destSlice reflect.Value, s *schema, rel *relation) error {
+	ownerField, err := e.relationKey(s, rel.References, rel.Name)
+	if err != nil {
+		return err
+	}
+	relatedField, err := e.relationKey(parseSchema(rel.ElemType), rel.ForeignKey, rel.Name)
+	if err != nil {
+		return err
+	}
+
+	related, err := e.findRelated(rel, rel.ForeignKey, collectKeys(destSlice, ownerField))
+	if err != nil {
+		return err
+	}
+
+	//按外键分组，填充到对应的记录
+	group := groupByKey(related, relatedField)
+	for i := 0; i < destSlice.Len(); i++ {
+		owner := destSlice.Index(i)
+		setRelation(owner.Field(rel.Index), group[keyString(owner.Field(ownerField.Index))])
+	}
+	return nil
+}
+
+// belongs_to：当前表的foreign_key引用关联表的references（默认主键）
+func (e *Orm) preloadBelongsTo(destSlice reflect.Value, rel *relation) error {
+	ownerField, err := e.relationKey(parseSchema(destSlice.Type().Elem()), rel.ForeignKey, rel.Name)
+	if err != nil {
+		return err
+	}
+	relatedField, err := e.relationKey(parseSchema(rel.ElemType), rel.References, rel.Name)
+	if err != nil {
+		return err
+	}
+
+	related, err := e.findRelated(rel, relatedField.Column, collectKeys(destSlice, ownerField))
+	if err != nil {
+		return err
+	}
+
+	group := groupByKey(related, relatedField)
+	for i := 0; i < destSlice.Len(); i++ {
+		owner := destSlice.Index(i)
+		setRelation(owner.Field(rel.Index), group[keyString(owner.Field(ownerField.Index))])
+	}
+	return nil
+}
+
+// many_to_many：中间表join_table的foreign_key引用当前表主键，references引用关联表主键
+func (e *Orm) preloadManyToMany(destSlice reflect.Value, s *schema, rel *relation) error {
+	if rel.JoinTable == "" || rel.References == "" {
+		return e.setErrorInfo(errors.New("关联" + rel.Name + "必须设置join_table和references"))
+	}
+	ownerField, err := e.relationKey(s, "", rel.Name)
+	if err != nil {
+		return err
+	}
+	relatedField, err := e.relationKey(parseSchema(rel.ElemType), "", rel.Name)
+	if err != nil {
+		return err
+	}
+
+	ownerKeys := collectKeys(destSlice, ownerField)
+	if len(ownerKeys) == 0 {
+		return nil
+	}
+
+	//查询中间表
+	joinRows, err := e.relatedQuery(rel.JoinTable).Field(rel.ForeignKey+","+rel.References).Where(rel.ForeignKey, "in", ownerKeys).Select()
+	if err != nil {
+		return err
+	}
+
+	//当前表主键对应的关联表主键
+	joinKeys := make(map[string][]string)
+	var relatedKeys []interface{}
+	seen := make(map[string]bool)
+	for _, row := range joinRows {
+		joinKeys[row[rel.ForeignKey]] = append(joinKeys[row[rel.ForeignKey]], row[rel.References])
+		if !seen[row[rel.References]] {
+			seen[row[rel.References]] = true
+			relatedKeys = append(relatedKeys, row[rel.References])
+		}
+	}
+
+	related, err := e.findRelated(rel, relatedField.Column, relatedKeys)
+	if err != nil {
+		return err
+	}
+
+	group := groupByKey(related, relatedField)
+	for i := 0; i < destSlice.Len(); i++ {
+		owner := destSlice.Index(i)
+		var items []reflect.Value
+		for _, key := range joinKeys[keyString(owner.Field(ownerField.Index))] {
+			items = append(items, group[key]...)
+		}
+		setRelation(owner.Field(rel.Index), items)
+	}
+	return nil
+}
+
+// 获取关联使用的字段，column为空时使用主键
+func (e *Orm) relationKey(s *schema, column string, name string) (*schemaField, error) {
+	if column == "" {
+		if len(s.PrimaryKeys) == 0 {
+			return nil, e.setErrorInfo(errors.New("关联" + name + "的结构体未定义主键"))
+		}
+		return s.PrimaryKeys[0], nil
+	}
+
+	field := s.fieldByColumn(column)
+	if field == nil {
+		return nil, e.setErrorInfo(errors.New("关联" + name + "的结构体中没有字段：" + column))
+	}
+	return field, nil
+}
+
+// 查询关联表中column在keys中的记录
+func (e *Orm) findRelated(rel *relation, column string, keys []interface{}) (reflect.Value, error) {
+	related := reflect.New(reflect.SliceOf(rel.ElemType))
+	if len(keys) == 0 {
+		return related.Elem(), nil
+	}
+
+	if err := e.relatedQuery(rel.Table).Where(column, "in", keys).Find(related.Interface()); err != nil {
+		return related.Elem(), err
+	}
+	return related.Elem(), nil
+}
+
+// 关联查询的构造器，沿用当前查询的UsePrimary()和AllowScatter()
+func (e *Orm) relatedQuery(table string) *Orm {
+	s := e.Session().Table(table)
+	s.IsPrimary = e.IsPrimary
+	s.IsScatter = e.IsScatter
+	return s
+}
+
+// 收集切片中某个字段的值，去重并跳过零值
+func collectKeys(destSlice reflect.Value, field *schemaField) []interface{} {
+	var keys []interface{}
+	seen := make(map[string]bool)
+	for i := 0; i < destSlice.Len(); i++ {
+		value := destSlice.Index(i).Field(field.Index)
+		if value.IsZero() || seen[keyString(value)] {
+			continue
+		}
+		seen[keyString(value)] = true
+		keys = append(keys, value.Interface())
+	}
+	return keys
+}
+
+// 按字段值分组
+func groupByKey(related reflect.Value, field *schemaField) map[string][]reflect.Value {
+	group := make(map[string][]reflect.Value)
+	for i := 0; i < related.Len(); i++ {
+		item := related.Index(i)
+		key := keyString(item.Field(field.Index))
+		group[key] = append(group[key], item)
+	}
+	return group
+}
+
+// 字段值转为字符串用于比较，避免int和int64等类型不一致
+func keyString(value reflect.Value) string {
+	return fmt.Sprint(value.Interface())
+}
+
+// 填充关联字段，支持T、*T、[]T、[]*T
+func setRelation(field reflect.Value, items []reflect.Value) {
+	switch field.Kind() {
+	case reflect.Slice:
+		list := reflect.MakeSlice(field.Type(), 0, len(items))
+		for _, item := range items {
+			if field.Type().Elem().Kind() == reflect.Ptr {
+				list = reflect.Append(list, item.Addr())
+			} else {
+				list = reflect.Append(list, item)
+			}
+		}
+		field.Set(list)
+	case reflect.Ptr:
+		if len(items) > 0 {
+			field.Set(items[0].Addr())
+		}
+	case reflect.Struct:
+		if len(items) > 0 {
+			field.Set(items[0])
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type relUser struct {
+	Uid     int64       `sql:"uid,auto_increment"`
+	Name    string      `sql:"name"`
+	Orders  []relOrder  `sql:",has_many,table=order,foreign_key=user_id"`
+	Profile *relProfile `sql:",has_one,table=profile,foreign_key=user_id"`
+	Roles   []*relRole  `sql:",many_to_many,table=role,join_table=user_role,foreign_key=user_id,references=role_id"`
+}
+
+type relOrder struct {
+	Id     int64    `sql:"id,auto_increment"`
+	UserId int64    `sql:"user_id"`
+	User   *relUser `sql:",belongs_to,table=user,foreign_key=user_id"`
+}
+
+type relProfile struct {
+	UserId int64  `sql:"user_id"`
+	Bio    string `sql:"bio"`
+}
+
+type relRole struct {
+	Id   int64  `sql:"id,auto_increment"`
+	Name string `sql:"name"`
+}
+
+// 按表名返回查询结果
+func fakeRelationData(c *fakeConnector) {
+	tables := []struct {
+		table   string
+		columns []string
+		data    [][]string
+	}{
+		{"`user_role`", []string{"user_id", "role_id"}, [][]string{{"1", "100"}, {"2", "100"}, {"2", "101"}}},
+		{"`user`", []string{"uid", "name"}, [][]string{{"1", "a"}, {"2", "b"}}},
+		{"`order`", []string{"id", "user_id"}, [][]string{{"10", "1"}, {"11", "1"}, {"12", "2"}}},
+		{"`profile`", []string{"user_id", "bio"}, [][]string{{"1", "x"}}},
+		{"`role`", []string{"id", "name"}, [][]string{{"100", "admin"}, {"101", "dev"}}},
+	}
+	c.query = func(q fakeQuery) ([]string, [][]string) {
+		for _, table := range tables {
+			if strings.Contains(q.query, "from "+table.table) {
+				return table.columns, table.data
+			}
+		}
+		return nil, nil
+	}
+}
+
+func TestPreload(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeRelationData(c)
+	e := newFakeOrm(t, c)
+
+	var users []relUser
+	if err := e.Table("user").Preload("Orders", "Profile", "Roles").Find(&users); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	//每个关联一次in查询，many_to_many先查中间表
+	executed := c.executed()
+	want := []fakeQuery{
+		{query: "select * from `user`"},
+		{query: "select * from `order` where (`user_id` in (?,?))", args: []driver.Value{int64(1), int64(2)}},
+		{query: "select * from `profile` where (`user_id` in (?,?))", args: []driver.Value{int64(1), int64(2)}},
+		{query: "select `user_id`,`role_id` from `user_role` where (`user_id` in (?,?))", args: []driver.Value{int64(1), int64(2)}},
+		{query: "select * from `role` where (`id` in (?,?))", args: []driver.Value{"100", "101"}},
+	}
+	if len(executed) != len(want) {
+		t.Fatalf("executed %d statements, want %d: %v", len(executed), len(want), executed)
+	}
+	for i, q := range executed {
+		if len(q.args) == 0 {
+			q.args = nil
+		}
+		if strings.TrimSpace(q.query) != want[i].query || !reflect.DeepEqual(q.args, want[i].args) {
+			t.Fatalf("executed[%d] = %q %v, want %q %v", i, q.query, q.args, want[i].query, want[i].args)
+		}
+	}
+
+	if len(users) != 2 || len(users[0].Orders) != 2 || len(users[1].Orders) != 1 || users[1].Orders[0].Id != 12 {
+		t.Fatalf("Orders = %+v", users)
+	}
+	if users[0].Profile == nil || users[0].Profile.Bio != "x" || users[1].Profile != nil {
+		t.Fatalf("Profile = %+v, %+v", users[0].Profile, users[1].Profile)
+	}
+	if len(users[0].Roles) != 1 || users[0].Roles[0].Name != "admin" || len(users[1].Roles) != 2 || users[1].Roles[1].Name != "dev" {
+		t.Fatalf("Roles = %+v, %+v", users[0].Roles, users[1].Roles)
+	}
+}
+
+func TestPreloadBelongsTo(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeRelationData(c)
+	e := newFakeOrm(t, c)
+
+	var orders []relOrder
+	if err := e.Table("order").Preload("User").Find(&orders); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `user` where (`uid` in (?,?))", int64(1), int64(2))
+	if len(orders) != 3 || orders[0].User == nil || orders[0].User.Name != "a" || orders[2].User.Name != "b" {
+		t.Fatalf("orders = %+v", orders)
+	}
+}
+
+func TestPreloadUndefined(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	fakeRelationData(c)
+	e := newFakeOrm(t, c)
+
+	var users []relUser
+	if err := e.Table("user").Preload("Comments").Find(&users); err == nil {
+		t.Fatal("Find() with undefined relation error = nil")
+	}
+}
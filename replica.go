@@ -0,0 +1,125 @@
+package orm
+
+import (
+	"database/sql"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 从库选择策略
+type ReplicaPolicy interface {
+	// 从replicas中选择一个，replicas不为空
+	Pick(replicas []*sql.DB) *sql.DB
+}
+
+// 需要查询耗时的策略实现此接口，每次从库查询后调用
+type LatencyObserver interface {
+	Observe(db *sql.DB, latency time.Duration)
+}
+
+// 设置从库，构造器的查询（Select、Find、Count等）按policy发往从库，增删改、事务、加锁查询及原生sql使用主库
+// policy为nil时使用轮询
+func (e *Orm) SetReplicas(policy ReplicaPolicy, replicas ...*sql.DB) *Orm {
+	if policy == nil {
+		policy = RoundRobinPolicy()
+	}
+	e.Replicas = replicas
+	e.Policy = policy
+	return e
+}
+
+// 本次查询使用主库，用于写入后立即读取
+func (e *Orm) UsePrimary() *Orm {
+	e.IsPrimary = true
+	return e
+}
+
+// 查询使用的连接，replica为选中的从库，使用主库或事务时为nil
+func (e *Orm) getReader(op string) (executor sqlExecutor, replica *sql.DB) {
+	if e.TransStatus == 1 && e.Tx != nil {
+		return e.Tx, nil
+	}
+	if e.ShardDb != nil && op != OpQuery {
+		return e.ShardDb, nil
+	}
+	if op != OpSelect || e.IsPrimary || e.LockParam != "" || len(e.Replicas) == 0 || e.Policy == nil {
+		return e.Db, nil
+	}
+	replica = e.Policy.Pick(e.Replicas)
+	return replica, replica
+}
+
+// 记录从库查询耗时
+func (e *Orm) observeReplica(replica *sql.DB, latency time.Duration) {
+	if observer, ok := e.Policy.(LatencyObserver); ok && replica != nil {
+		observer.Observe(replica, latency)
+	}
+}
+
+// 轮询
+func RoundRobinPolicy() ReplicaPolicy {
+	return &roundRobinPolicy{}
+}
+
+type roundRobinPolicy struct {
+	next uint64
+}
+
+func (p *roundRobinPolicy) Pick(replicas []*sql.DB) *sql.DB {
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+// 随机
+func RandomPolicy() ReplicaPolicy {
+	return randomPolicy{}
+}
+
+type randomPolicy struct{}
+
+func (randomPolicy) Pick(replicas []*sql.DB) *sql.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+// 最低延迟，按查询耗时的滑动平均选择，未查询过的从库优先
+func LeastLatencyPolicy() ReplicaPolicy {
+	return &leastLatencyPolicy{latency: make(map[*sql.DB]time.Duration)}
+}
+
+type leastLatencyPolicy struct {
+	mu      sync.Mutex
+	latency map[*sql.DB]time.Duration
+}
+
+func (p *leastLatencyPolicy) Pick(replicas []*sql.DB) *sql.DB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	best := replicas[0]
+	bestLatency, ok := p.latency[best]
+	if !ok {
+		return best
+	}
+	for _, db := range replicas[1:] {
+		latency, ok := p.latency[db]
+		if !ok {
+			return db
+		}
+		if latency < bestLatency {
+			best, bestLatency = db, latency
+		}
+	}
+	return best
+}
+
+// 滑动平均，最近一次占1/5
+func (p *leastLatencyPolicy) Observe(db *sql.DB, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.latency[db]; ok {
+		latency = (old*4 + latency) / 5
+	}
+	p.latency[db] = latency
+}
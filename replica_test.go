@@ -0,0 +1,136 @@
+package orm
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// 主库和两个从库，返回相同的结果
+func newReplicaOrm(t *testing.T, policy ReplicaPolicy) (e *Orm, primary *fakeConnector, replicas []*fakeConnector) {
+	t.Helper()
+	columns, data := []string{"uid", "name"}, [][]string{{"1", "a"}}
+	primary = &fakeConnector{columns: columns, data: data, failAt: -1}
+	replicas = []*fakeConnector{
+		{columns: columns, data: data, failAt: -1},
+		{columns: columns, data: data, failAt: -1},
+	}
+	e = newFakeOrm(t, primary).SetReplicas(policy, openFakeDb(t, replicas[0]), openFakeDb(t, replicas[1]))
+	return e, primary, replicas
+}
+
+func TestReplicaRead(t *testing.T) {
+	e, primary, replicas := newReplicaOrm(t, nil)
+
+	//轮询发往从库
+	if _, err := e.Table("user").Where("uid", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, replicas[0], "select * from `user` where (`uid`=?)", int64(1))
+
+	replicas[1].columns, replicas[1].data = []string{"cnt"}, [][]string{{"1"}}
+	if n, err := e.Table("user").Where("uid", 1).Count(); err != nil || n != 1 {
+		t.Fatalf("Count() = %d, %v", n, err)
+	}
+	assertLastQuery(t, replicas[1], "select count(*) as cnt from `user` where (`uid`=?)", int64(1))
+
+	var users []fakeUser
+	if err := e.Table("user").Where("uid", 2).Find(&users); err != nil || len(users) != 1 {
+		t.Fatalf("Find() = %v, %v", users, err)
+	}
+	assertLastQuery(t, replicas[0], "select * from `user` where (`uid`=?)", int64(2))
+	assertNoQuery(t, primary)
+}
+
+func TestReplicaPrimary(t *testing.T) {
+	e, primary, replicas := newReplicaOrm(t, nil)
+
+	if _, err := e.Table("user").UsePrimary().Where("uid", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, primary, "select * from `user` where (`uid`=?)", int64(1))
+
+	//原生sql
+	if _, err := e.Query("select * from user where uid=?", 2); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	assertLastQuery(t, primary, "select * from user where uid=?", int64(2))
+	if _, err := e.Raw("select * from user where uid=?", 3).Select(); err != nil {
+		t.Fatalf("Raw().Select() error = %v", err)
+	}
+	assertLastQuery(t, primary, "select * from user where uid=?", int64(3))
+	if _, err := e.Exec("update user set name=? where uid=?", "a", 4); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	assertLastQuery(t, primary, "update user set name=? where uid=?", "a", int64(4))
+
+	//增删改
+	if _, err := e.Table("user").Where("uid", 5).Update("name", "b"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, primary, "update `user` set `name`=? where (`uid`=?)", "b", int64(5))
+
+	//事务中的查询和加锁查询
+	if err := e.Begin(); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := e.Table("user").Where("uid", 6).Select(); err != nil {
+		t.Fatalf("Select() in transaction error = %v", err)
+	}
+	assertLastQuery(t, primary, "select * from `user` where (`uid`=?)", int64(6))
+	if _, err := e.Table("user").Where("uid", 7).LockForUpdate().Select(); err != nil {
+		t.Fatalf("Select() for update error = %v", err)
+	}
+	assertLastQuery(t, primary, "select * from `user` where (`uid`=?)  for update", int64(7))
+	if err := e.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	assertNoQuery(t, replicas[0])
+	assertNoQuery(t, replicas[1])
+
+	//UsePrimary只对本次查询有效
+	if _, err := e.Table("user").Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, replicas[0], "select * from `user`")
+}
+
+func TestLeastLatencyPolicy(t *testing.T) {
+	policy := LeastLatencyPolicy().(*leastLatencyPolicy)
+	e, _, replicas := newReplicaOrm(t, policy)
+	dbs := e.Replicas
+
+	//未查询过的从库优先，查询后记录耗时
+	for i := range replicas {
+		if _, err := e.Table("user").Select(); err != nil {
+			t.Fatalf("Select() error = %v", err)
+		}
+		assertLastQuery(t, replicas[i], "select * from `user`")
+		if _, ok := policy.latency[dbs[i]]; !ok {
+			t.Fatalf("latency of replica %d not observed", i)
+		}
+	}
+
+	//选择耗时最低的从库
+	policy.latency[dbs[0]] = 10 * time.Second
+	policy.latency[dbs[1]] = time.Millisecond
+	before := len(replicas[0].executed())
+	if _, err := e.Table("user").Where("uid", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, replicas[1], "select * from `user` where (`uid`=?)", int64(1))
+	if len(replicas[0].executed()) != before {
+		t.Fatal("slow replica executed")
+	}
+
+	//滑动平均
+	policy.latency[dbs[1]] = time.Millisecond
+	policy.Observe(dbs[1], 51*time.Millisecond)
+	if got := policy.latency[dbs[1]]; got != 11*time.Millisecond {
+		t.Fatalf("latency = %v, want 11ms", got)
+	}
+	if got := policy.Pick([]*sql.DB{dbs[0], dbs[1]}); got != dbs[1] {
+		t.Fatal("Pick() is not the replica with least latency")
+	}
+}
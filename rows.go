@@ -0,0 +1,51 @@
+package orm
+
+import "database/sql"
+
+// 遍历查询结果的每一行，values为当前行各列的原始值，读取下一行时会被覆盖；返回处理的行数
+// fn返回错误时停止遍历，rows的关闭和rows.Err()由queryStatement处理
+func eachRow(rows *sql.Rows, fn func(columns []string, values [][]byte) error) (int64, error) {
+	//读出查询出的列字段名
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	//values是每个列的值，这里获取到byte里，因为每次查询出来的列是不定长的，用len(columns)定住当次查询的长度
+	values := make([][]byte, len(columns))
+	scans := make([]interface{}, len(columns))
+	for i := range values {
+		scans[i] = &values[i]
+	}
+
+	var num int64
+	for rows.Next() {
+		if err := rows.Scan(scans...); err != nil {
+			return num, err
+		}
+		if err := fn(columns, values); err != nil {
+			return num, err
+		}
+		num++
+	}
+	return num, nil
+}
+
+// 查询并将每一行转为map，key为列名
+func (e *Orm) queryMaps(op string, query string, args []interface{}) ([]map[string]string, error) {
+	results := make([]map[string]string, 0)
+	err := e.queryStatement(op, query, args, func(rows *sql.Rows) (int64, error) {
+		return eachRow(rows, func(columns []string, values [][]byte) error {
+			row := make(map[string]string, len(columns))
+			for k, v := range values {
+				row[columns[k]] = string(v)
+			}
+			results = append(results, row)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,271 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// 查询结果中的NULL
+const fakeNull = "\x00NULL"
+
+// 模拟驱动：每次查询返回相同的结果，failAt>=0时读取到第failAt行返回错误
+// 记录预处理、关闭的语句个数和执行的语句
+type fakeConnector struct {
+	columns  []string
+	data     [][]string //值为fakeNull时返回NULL
+	failAt   int
+	opened   int                                      //未关闭的结果集个数
+	affected int64                                    //Exec影响的行数
+	exec     func(q fakeQuery) (driver.Result, error) //设置时由其返回Exec的结果
+	query    func(q fakeQuery) ([]string, [][]string) //设置时由其返回查询的列和数据
+
+	mu        sync.Mutex
+	prepared  int         //预处理的语句个数
+	closed    int         //关闭的语句个数
+	queries   []fakeQuery //执行的语句和参数
+	commits   int         //提交的事务个数
+	rollbacks int         //回滚的事务个数
+}
+
+type fakeQuery struct {
+	query string
+	args  []driver.Value
+}
+
+type fakeConn struct{ c *fakeConnector }
+type fakeStmt struct {
+	c     *fakeConnector
+	query string
+}
+type fakeTx struct{ c *fakeConnector }
+type fakeResult struct{ id, affected int64 }
+type fakeRows struct {
+	c       *fakeConnector
+	columns []string
+	data    [][]string
+	i       int
+}
+
+var errFakeNext = errors.New("fake: connection reset")
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+// 预处理和关闭的语句个数
+func (c *fakeConnector) stmtCount() (prepared int, closed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.prepared, c.closed
+}
+
+// 执行过的语句
+func (c *fakeConnector) executed() []fakeQuery {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]fakeQuery(nil), c.queries...)
+}
+
+func (c *fakeConnector) record(query string, args []driver.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, fakeQuery{query: query, args: args})
+}
+
+func (f fakeConn) Prepare(query string) (driver.Stmt, error) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	f.c.prepared++
+	return fakeStmt{c: f.c, query: query}, nil
+}
+func (fakeConn) Close() error                { return nil }
+func (f fakeConn) Begin() (driver.Tx, error) { return fakeTx{f.c}, nil }
+
+func (tx fakeTx) Commit() error {
+	tx.c.mu.Lock()
+	defer tx.c.mu.Unlock()
+	tx.c.commits++
+	return nil
+}
+func (tx fakeTx) Rollback() error {
+	tx.c.mu.Lock()
+	defer tx.c.mu.Unlock()
+	tx.c.rollbacks++
+	return nil
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+func (s fakeStmt) Close() error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.c.closed++
+	return nil
+}
+func (fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.record(s.query, args)
+	if s.c.exec != nil {
+		return s.c.exec(fakeQuery{query: s.query, args: args})
+	}
+	return driver.RowsAffected(s.c.affected), nil
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.record(s.query, args)
+	s.c.opened++
+	rows := &fakeRows{c: s.c, columns: s.c.columns, data: s.c.data}
+	if s.c.query != nil {
+		rows.columns, rows.data = s.c.query(fakeQuery{query: s.query, args: args})
+	}
+	return rows, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error {
+	r.c.opened--
+	return nil
+}
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == r.c.failAt {
+		return errFakeNext
+	}
+	if r.i >= len(r.data) {
+		return io.EOF
+	}
+	for k, v := range r.data[r.i] {
+		if v == fakeNull {
+			dest[k] = nil
+			continue
+		}
+		dest[k] = []byte(v)
+	}
+	r.i++
+	return nil
+}
+
+type fakeUser struct {
+	Uid  int64  `sql:"uid"`
+	Name string `sql:"name"`
+}
+
+func newFakeOrm(t *testing.T, c *fakeConnector) *Orm {
+	t.Helper()
+	db := sql.OpenDB(c)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return &Orm{Db: db, FieldParam: "*", Dialect: MysqlDialect{}}
+}
+
+// 查询结束后连接归还连接池，结果集已关闭
+func assertReleased(t *testing.T, e *Orm, c *fakeConnector) {
+	t.Helper()
+	if inUse := e.Db.Stats().InUse; inUse != 0 {
+		t.Fatalf("connections in use = %d, want 0", inUse)
+	}
+	if c.opened != 0 {
+		t.Fatalf("open rows = %d, want 0", c.opened)
+	}
+}
+
+func TestReadPathsReleaseConnection(t *testing.T) {
+	c := &fakeConnector{
+		columns: []string{"uid", "name"},
+		data:    [][]string{{"1", "a"}, {"2", "b"}},
+		failAt:  -1,
+	}
+	e := newFakeOrm(t, c)
+
+	rows, err := e.Table("user").Select()
+	if err != nil || len(rows) != 2 || rows[1]["name"] != "b" {
+		t.Fatalf("Select() = %v, %v", rows, err)
+	}
+	assertReleased(t, e, c)
+
+	rows, err = e.Query("select * from user where uid > ?", 0)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Query() = %v, %v", rows, err)
+	}
+	assertReleased(t, e, c)
+
+	var users []fakeUser
+	if err := e.Table("user").Find(&users); err != nil || len(users) != 2 || users[0].Uid != 1 {
+		t.Fatalf("Find() = %v, %v", users, err)
+	}
+	assertReleased(t, e, c)
+
+	var user fakeUser
+	if err := e.Raw("select * from user").FindOne(&user); err != nil || user.Name != "a" {
+		t.Fatalf("FindOne() = %v, %v", user, err)
+	}
+	assertReleased(t, e, c)
+
+	//只读取第一行，剩余的行未读完
+	var uid int64
+	var name string
+	if err := e.Raw("select uid,name from user").Scan(&uid, &name); err != nil || uid != 1 {
+		t.Fatalf("Scan() = %d, %v", uid, err)
+	}
+	assertReleased(t, e, c)
+}
+
+func TestReadPathsReturnIterationError(t *testing.T) {
+	c := &fakeConnector{
+		columns: []string{"uid", "name"},
+		data:    [][]string{{"1", "a"}, {"2", "b"}},
+		failAt:  1,
+	}
+	e := newFakeOrm(t, c)
+
+	if _, err := e.Table("user").Select(); !errors.Is(err, errFakeNext) {
+		t.Fatalf("Select() error = %v, want %v", err, errFakeNext)
+	}
+	assertReleased(t, e, c)
+
+	if _, err := e.Query("select * from user"); !errors.Is(err, errFakeNext) {
+		t.Fatalf("Query() error = %v, want %v", err, errFakeNext)
+	}
+	assertReleased(t, e, c)
+
+	var users []fakeUser
+	if err := e.Table("user").Find(&users); !errors.Is(err, errFakeNext) {
+		t.Fatalf("Find() error = %v, want %v", err, errFakeNext)
+	}
+	assertReleased(t, e, c)
+}
+
+func TestFindReleasesConnectionOnScanError(t *testing.T) {
+	c := &fakeConnector{
+		columns: []string{"uid", "name"},
+		data:    [][]string{{"x", "a"}, {"2", "b"}},
+		failAt:  -1,
+	}
+	e := newFakeOrm(t, c)
+
+	var users []fakeUser
+	if err := e.Table("user").Find(&users); err == nil {
+		t.Fatal("Find() error = nil, want parse error")
+	}
+	assertReleased(t, e, c)
+
+	//连接池只有一个连接，泄漏时这里会阻塞
+	if _, err := e.Table("user").Count(); err == nil {
+		t.Fatal("Count() error = nil, want scan error")
+	}
+	assertReleased(t, e, c)
+}
+
+func TestScanNoRows(t *testing.T) {
+	c := &fakeConnector{columns: []string{"uid"}, failAt: -1}
+	e := newFakeOrm(t, c)
+
+	var uid int64
+	if err := e.Raw("select uid from user").Scan(&uid); !errors.Is(err, ErrRecordNotFound) {
+		t.Fatalf("Scan() error = %v, want %v", err, ErrRecordNotFound)
+	}
+	assertReleased(t, e, c)
+}
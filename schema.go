@@ -0,0 +1,173 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// 结构体字段与表字段的对应关系
+type schemaField struct {
+	Name           string //结构体字段名
+	Column         string //表字段名
+	Index          int    //结构体中的下标
+	AutoIncrement  bool   //自增字段
+	PrimaryKey     bool   //主键字段
+	SoftDelete     bool   //软删除时间字段
+	AutoCreateTime bool   //插入时自动填充的创建时间字段
+	AutoUpdateTime bool   //插入和更新时自动填充的更新时间字段
+	Version        bool   //乐观锁版本号字段
+}
+
+// 关联关系
+type relation struct {
+	Name       string       //结构体字段名
+	Index      int          //结构体中的下标
+	Kind       string       //has_one、has_many、belongs_to、many_to_many
+	Table      string       //关联表名
+	ForeignKey string       //外键字段
+	References string       //外键引用的字段
+	JoinTable  string       //many_to_many的中间表
+	ElemType   reflect.Type //关联结构体类型
+}
+
+// 结构体解析结果
+type schema struct {
+	Fields         []*schemaField
+	AutoIncrement  *schemaField
+	PrimaryKeys    []*schemaField //主键，支持联合主键
+	SoftDelete     *schemaField
+	AutoUpdateTime *schemaField
+	Relations      map[string]*relation //关联关系，key为结构体字段名
+}
+
+// 解析结果缓存，key为reflect.Type
+var schemaCache sync.Map
+
+// 解析结构体的sql tag，如`sql:"uid,auto_increment"`，第一个为表字段名，后面为选项
+func parseSchema(t reflect.Type) *schema {
+	if cached, ok := schemaCache.Load(t); ok {
+		return cached.(*schema)
+	}
+
+	s := &schema{}
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+
+		//小写开头，无法反射，跳过
+		if !structField.IsExported() {
+			continue
+		}
+
+		field := &schemaField{
+			Name:   structField.Name,
+			Column: structField.Name,
+			Index:  i,
+		}
+
+		//关联关系选项，如`sql:",has_many,table=order,foreign_key=user_id"`
+		var rel *relation
+		getRelation := func() *relation {
+			if rel == nil {
+				rel = &relation{Name: structField.Name, Index: i, ElemType: relationElemType(structField.Type)}
+			}
+			return rel
+		}
+
+		sqlTag := structField.Tag.Get("sql")
+		if sqlTag != "" {
+			options := strings.Split(sqlTag, ",")
+			if options[0] != "" {
+				field.Column = options[0]
+			}
+			for _, option := range options[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+				switch strings.ToLower(key) {
+				case "auto_increment":
+					field.AutoIncrement = true
+				case "pk":
+					field.PrimaryKey = true
+				case "soft_delete":
+					field.SoftDelete = true
+				case "autocreatetime":
+					field.AutoCreateTime = true
+				case "autoupdatetime":
+					field.AutoUpdateTime = true
+				case "version":
+					field.Version = true
+				case "has_one", "has_many", "belongs_to", "many_to_many":
+					getRelation().Kind = strings.ToLower(key)
+				case "table":
+					getRelation().Table = value
+				case "foreign_key":
+					getRelation().ForeignKey = value
+				case "references":
+					getRelation().References = value
+				case "join_table":
+					getRelation().JoinTable = value
+				}
+			}
+		}
+
+		//关联字段不是表字段
+		if rel != nil && rel.Kind != "" {
+			if s.Relations == nil {
+				s.Relations = make(map[string]*relation)
+			}
+			s.Relations[rel.Name] = rel
+			continue
+		}
+
+		if field.AutoIncrement && s.AutoIncrement == nil {
+			s.AutoIncrement = field
+		}
+		if field.PrimaryKey {
+			s.PrimaryKeys = append(s.PrimaryKeys, field)
+		}
+		if field.SoftDelete && s.SoftDelete == nil {
+			s.SoftDelete = field
+		}
+		if field.AutoUpdateTime && s.AutoUpdateTime == nil {
+			s.AutoUpdateTime = field
+		}
+		s.Fields = append(s.Fields, field)
+	}
+
+	//未标记主键时，自增字段作为主键
+	if len(s.PrimaryKeys) == 0 && s.AutoIncrement != nil {
+		s.AutoIncrement.PrimaryKey = true
+		s.PrimaryKeys = append(s.PrimaryKeys, s.AutoIncrement)
+	}
+
+	schemaCache.Store(t, s)
+	return s
+}
+
+// 按表字段名查找结构体字段
+func (s *schema) fieldByColumn(column string) *schemaField {
+	for _, field := range s.Fields {
+		if field.Column == column {
+			return field
+		}
+	}
+	return nil
+}
+
+// 关联字段的结构体类型，支持T、*T、[]T、[]*T
+func relationElemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// 取出接口和指针指向的实际值
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
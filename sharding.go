@@ -0,0 +1,239 @@
+package orm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 分片，DB为nil时使用主库
+type Shard struct {
+	DB    *sql.DB
+	Table string
+}
+
+// 逻辑表的分片规则
+type ShardingRule struct {
+	Column    string                               //分片字段
+	Shards    []Shard                              //所有分片，Algorithm返回其下标
+	Algorithm func(value interface{}) (int, error) //按分片字段的值计算分片下标
+}
+
+// 分片插件，按逻辑表注册分片规则
+type Sharding struct {
+	rules map[string]*ShardingRule
+}
+
+// 新建分片插件
+func NewSharding() *Sharding {
+	return &Sharding{rules: make(map[string]*ShardingRule)}
+}
+
+// 注册逻辑表的分片规则，如Register("order", orm.ShardingRule{Column: "user_id", Shards: orm.SplitShards("order", 64, db0, db1), Algorithm: orm.HashMod(64)})
+func (s *Sharding) Register(table string, rule ShardingRule) *Sharding {
+	s.rules[table] = &rule
+	return s
+}
+
+// 使用分片插件，Table()传入逻辑表名，执行时按where条件或插入的数据改写为实际的表名和连接
+func (e *Orm) UseSharding(s *Sharding) *Orm {
+	e.Sharding = s
+	return e
+}
+
+// 允许无法路由到单个分片的查询、更新和删除在所有涉及的分片上执行并合并结果
+func (e *Orm) AllowScatter() *Orm {
+	e.IsScatter = true
+	return e
+}
+
+// 将表拆分到多个库，如SplitShards("order", 64, db0, db1)生成order_00~order_63，前32张表在db0，后32张在db1
+func SplitShards(table string, tables int, dbs ...*sql.DB) []Shard {
+	width := len(strconv.Itoa(tables - 1))
+	if width < 2 {
+		width = 2
+	}
+
+	shards := make([]Shard, tables)
+	for i := range shards {
+		shards[i].Table = fmt.Sprintf("%s_%0*d", table, width, i)
+		if len(dbs) > 0 {
+			perDb := (tables + len(dbs) - 1) / len(dbs)
+			shards[i].DB = dbs[i/perDb]
+		}
+	}
+	return shards
+}
+
+// 取模分片，整数直接取模，字符串按crc32取模
+func HashMod(n int) func(value interface{}) (int, error) {
+	return func(value interface{}) (int, error) {
+		v := indirectValue(reflect.ValueOf(value))
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i := v.Int() % int64(n)
+			if i < 0 {
+				i = -i
+			}
+			return int(i), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(v.Uint() % uint64(n)), nil
+		case reflect.String:
+			return int(crc32.ChecksumIEEE([]byte(v.String())) % uint32(n)), nil
+		}
+		return 0, fmt.Errorf("不支持的分片字段类型：%T", value)
+	}
+}
+
+// 记录where中的等值和in条件，用于分片路由
+func (e *Orm) addWhereKey(column interface{}, values ...interface{}) {
+	name, ok := column.(string)
+	if !ok {
+		return
+	}
+	name = name[strings.LastIndex(name, ".")+1:]
+	if e.WhereKeys == nil {
+		e.WhereKeys = make(map[string][]interface{})
+	}
+	e.WhereKeys[name] = append(e.WhereKeys[name], values...)
+}
+
+// 当前表的分片规则，未分片或已路由时为nil
+func (e *Orm) shardingRule() *ShardingRule {
+	if e.Sharding == nil || e.TableName == "" {
+		return nil
+	}
+	return e.Sharding.rules[e.TableName]
+}
+
+// 按where条件路由，单个分片时改写表名和连接并返回nil，AllowScatter()时返回涉及的所有分片
+// where中没有分片字段的等值/in条件或包含or条件时无法确定分片
+func (e *Orm) route() ([]Shard, error) {
+	rule := e.shardingRule()
+	if rule == nil {
+		return nil, nil
+	}
+
+	var values []interface{}
+	if !e.IsOrWhere {
+		values = e.WhereKeys[rule.Column]
+	}
+	shards, err := e.resolveShards(rule, values)
+	if err != nil {
+		return nil, e.setErrorInfo(err)
+	}
+	if len(shards) == 1 {
+		return nil, e.setErrorInfo(e.useShard(shards[0]))
+	}
+	if !e.IsScatter {
+		return nil, e.setErrorInfo(errors.New("无法路由到单个分片，请在where中指定分片字段" + rule.Column + "，或调用AllowScatter()"))
+	}
+
+	//每个分片单独执行，排序、分页和分组的结果无法合并
+	if e.LimitParam != "" || e.OrderParam != "" || e.GroupParam != "" || e.HavingParam != "" {
+		return nil, e.setErrorInfo(errors.New("跨分片执行不支持limit、order、group和having"))
+	}
+	return shards, nil
+}
+
+// 按插入数据中的分片字段路由，所有数据必须属于同一个分片
+func (e *Orm) routeInsert(items []reflect.Value) error {
+	rule := e.shardingRule()
+	if rule == nil || len(items) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		field := parseSchema(item.Type()).fieldByColumn(rule.Column)
+		if field == nil {
+			return e.setErrorInfo(errors.New("插入的数据缺少分片字段" + rule.Column))
+		}
+		values[i] = item.Field(field.Index).Interface()
+	}
+
+	shards, err := e.resolveShards(rule, values)
+	if err != nil {
+		return e.setErrorInfo(err)
+	}
+	if len(shards) != 1 {
+		return e.setErrorInfo(errors.New("插入的数据必须属于同一个分片"))
+	}
+	return e.setErrorInfo(e.useShard(shards[0]))
+}
+
+// 计算values所在的分片，values为空时返回所有分片
+func (e *Orm) resolveShards(rule *ShardingRule, values []interface{}) ([]Shard, error) {
+	if len(values) == 0 {
+		return rule.Shards, nil
+	}
+
+	var shards []Shard
+	seen := make(map[int]bool)
+	for _, value := range values {
+		i, err := rule.Algorithm(value)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= len(rule.Shards) {
+			return nil, fmt.Errorf("分片下标%d超出范围", i)
+		}
+		if !seen[i] {
+			seen[i] = true
+			shards = append(shards, rule.Shards[i])
+		}
+	}
+	return shards, nil
+}
+
+// 改写为实际的表名和连接，事务只能在主库上
+func (e *Orm) useShard(shard Shard) error {
+	if shard.DB != nil && shard.DB != e.Db && e.TransStatus == 1 {
+		return errors.New("事务中不能操作其他库的分片" + shard.Table)
+	}
+	if e.LogicalTable == "" {
+		e.LogicalTable = e.TableName
+	}
+	e.TableName = shard.Table
+	e.ShardDb = shard.DB
+	return nil
+}
+
+// 执行完毕后恢复逻辑表名和主库，之后的原生sql、事务和查询重新路由，不受本次路由的影响
+func (e *Orm) unroute() {
+	if e.LogicalTable != "" {
+		e.TableName = e.LogicalTable
+		e.LogicalTable = ""
+	}
+	e.ShardDb = nil
+}
+
+// 在每个分片上执行fn，fn的参数为改写了表名和连接的副本
+func (e *Orm) scatter(shards []Shard, fn func(s *Orm) error) error {
+	for _, shard := range shards {
+		s := *e
+		if err := s.useShard(shard); err != nil {
+			return e.setErrorInfo(err)
+		}
+		err := fn(&s)
+
+		//记录最后执行的语句
+		e.Prepare, e.AllExec, e.Sql = s.Prepare, s.AllExec, s.Sql
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 当前使用的连接池，路由到分片时为分片所在的库
+func (e *Orm) getDb() *sql.DB {
+	if e.ShardDb != nil {
+		return e.ShardDb
+	}
+	return e.Db
+}
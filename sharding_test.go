@@ -0,0 +1,306 @@
+package orm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type shardOrder struct {
+	Id     int64 `sql:"id"`
+	UserId int64 `sql:"user_id"`
+}
+
+func openFakeDb(t *testing.T, c *fakeConnector) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// order按user_id分为4张表，order_00、order_01在shards[0]，order_02、order_03在shards[1]
+func newShardedOrm(t *testing.T, columns []string, data [][]string) (e *Orm, primary *fakeConnector, shards []*fakeConnector) {
+	t.Helper()
+	primary = &fakeConnector{columns: columns, data: data, failAt: -1}
+	shards = []*fakeConnector{
+		{columns: columns, data: data, failAt: -1},
+		{columns: columns, data: data, failAt: -1},
+	}
+	e = newFakeOrm(t, primary)
+	e.UseSharding(NewSharding().Register("order", ShardingRule{
+		Column:    "user_id",
+		Shards:    SplitShards("order", 4, openFakeDb(t, shards[0]), openFakeDb(t, shards[1])),
+		Algorithm: HashMod(4),
+	}))
+	return e, primary, shards
+}
+
+// 断言c上最后执行的语句
+func assertLastQuery(t *testing.T, c *fakeConnector, query string, args ...driver.Value) {
+	t.Helper()
+	executed := c.executed()
+	if len(executed) == 0 {
+		t.Fatalf("no statement executed, want %q", query)
+	}
+	last := executed[len(executed)-1]
+	if len(last.args) == 0 {
+		last.args = nil
+	}
+	if strings.TrimSpace(last.query) != query || !reflect.DeepEqual(last.args, args) {
+		t.Fatalf("executed %q %v, want %q %v", last.query, last.args, query, args)
+	}
+}
+
+func assertNoQuery(t *testing.T, c *fakeConnector) {
+	t.Helper()
+	if executed := c.executed(); len(executed) != 0 {
+		t.Fatalf("executed %v, want none", executed)
+	}
+}
+
+func TestSplitShards(t *testing.T) {
+	db0, db1 := &sql.DB{}, &sql.DB{}
+
+	shards := SplitShards("order", 4, db0, db1)
+	want := []Shard{{db0, "order_00"}, {db0, "order_01"}, {db1, "order_02"}, {db1, "order_03"}}
+	if !reflect.DeepEqual(shards, want) {
+		t.Fatalf("SplitShards() = %v, want %v", shards, want)
+	}
+
+	//表数不能被库数整除时，最后一个库的表较少
+	shards = SplitShards("order", 5, db0, db1)
+	for i, db := range []*sql.DB{db0, db0, db0, db1, db1} {
+		if shards[i].DB != db {
+			t.Fatalf("shards[%d].DB is not db%d", i, map[*sql.DB]int{db0: 0, db1: 1}[db])
+		}
+	}
+
+	shards = SplitShards("log", 1000)
+	if len(shards) != 1000 || shards[0].Table != "log_000" || shards[999].Table != "log_999" || shards[0].DB != nil {
+		t.Fatalf("SplitShards(1000) = %v ... %v", shards[0], shards[len(shards)-1])
+	}
+}
+
+func TestHashMod(t *testing.T) {
+	n := int64(7)
+	tests := []struct {
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{value: 7, want: 3},
+		{value: int64(-7), want: 3},
+		{value: uint8(9), want: 1},
+		{value: &n, want: 3},
+		{value: "abc", want: 2}, //crc32("abc") = 0x352441c2
+		{value: 1.5, wantErr: true},
+		{value: nil, wantErr: true},
+	}
+	mod := HashMod(4)
+	for _, tt := range tests {
+		got, err := mod(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Fatalf("HashMod(4)(%v) = %d, %v, want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveShards(t *testing.T) {
+	rule := &ShardingRule{Column: "user_id", Shards: SplitShards("order", 4), Algorithm: HashMod(4)}
+	e := &Orm{}
+
+	shards, err := e.resolveShards(rule, []interface{}{1, 5, 2, 9})
+	if err != nil || len(shards) != 2 || shards[0].Table != "order_01" || shards[1].Table != "order_02" {
+		t.Fatalf("resolveShards() = %v, %v, want order_01, order_02", shards, err)
+	}
+
+	if shards, err = e.resolveShards(rule, nil); err != nil || len(shards) != 4 {
+		t.Fatalf("resolveShards(nil) = %v, %v, want all shards", shards, err)
+	}
+
+	rule.Algorithm = func(interface{}) (int, error) { return 4, nil }
+	if _, err = e.resolveShards(rule, []interface{}{1}); err == nil {
+		t.Fatal("resolveShards() error = nil, want out of range")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	e, primary, shards := newShardedOrm(t, []string{"id", "user_id"}, [][]string{{"1", "3"}})
+
+	var orders []shardOrder
+	if err := e.Table("order").Where("user_id", 3).Find(&orders); err != nil || len(orders) != 1 {
+		t.Fatalf("Find() = %v, %v", orders, err)
+	}
+	assertLastQuery(t, shards[1], "select * from `order_03` where (`user_id`=?)", int64(3))
+	if e.TableName != "order" || e.ShardDb != nil {
+		t.Fatalf("routing state kept after Find: TableName = %s, ShardDb = %v", e.TableName, e.ShardDb)
+	}
+
+	//in条件的值都在同一个分片
+	if _, err := e.Table("order").Where("user_id", "in", []int{1, 5}).Where("id", 2).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, shards[0], "select * from `order_01` where (`user_id` in (?,?))  and (`id`=?)", int64(1), int64(5), int64(2))
+
+	//上次路由不影响原生sql
+	if _, err := e.Query("select id from config"); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	assertLastQuery(t, primary, "select id from config")
+	if _, err := e.Exec("update config set v=?", 1); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	assertLastQuery(t, primary, "update config set v=?", int64(1))
+
+	if _, err := e.Table("order").Where("user_id", 2).Update("id", 9); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, shards[1], "update `order_02` set `id`=? where (`user_id`=?)", int64(9), int64(2))
+
+	if _, err := e.Table("order").Insert(&shardOrder{Id: 1, UserId: 4}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, shards[0], "insert into `order_00` (`id`,`user_id`) values (?,?)", int64(1), int64(4))
+
+	if _, err := e.Table("order").Insert([]shardOrder{{UserId: 1}, {UserId: 2}}); err == nil {
+		t.Fatal("Insert() into two shards error = nil")
+	}
+	if _, err := e.Table("order").Insert(&fakeUser{Uid: 1}); err == nil {
+		t.Fatal("Insert() without sharding column error = nil")
+	}
+
+	//无法确定分片
+	if _, err := e.Table("order").Where("id", 1).Select(); err == nil {
+		t.Fatal("Select() without sharding column error = nil")
+	}
+	if _, err := e.Table("order").Where("user_id", 1).OrWhere("user_id", 2).Delete(); err == nil {
+		t.Fatal("Delete() with or condition error = nil")
+	}
+	if _, err := e.Table("order").Where("user_id", "in", []int{1, 2}).Count(); err == nil {
+		t.Fatal("Count() on two shards error = nil")
+	}
+	if n := len(primary.executed()); n != 2 {
+		t.Fatalf("primary executed %d statements, want 2", n)
+	}
+}
+
+func TestRouteInTransaction(t *testing.T) {
+	e, primary, shards := newShardedOrm(t, []string{"id", "user_id"}, [][]string{{"1", "3"}})
+
+	var orders []shardOrder
+	if err := e.Table("order").Where("user_id", 3).Find(&orders); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+
+	//同一个构造器在事务中写入时重新路由，事务在主库上，分片在其他库时返回错误
+	if err := e.Begin(); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, err := e.Update("id", 2); err == nil {
+		t.Fatal("Update() on another database in transaction error = nil")
+	}
+	e.DisablePrepare = true
+	if _, err := e.Delete(); err == nil {
+		t.Fatal("Delete() on another database in transaction error = nil")
+	}
+	if err := e.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	assertNoQuery(t, primary)
+	if n := len(shards[1].executed()); n != 1 {
+		t.Fatalf("shard executed %d statements, want 1", n)
+	}
+}
+
+func TestScatter(t *testing.T) {
+	e, primary, shards := newShardedOrm(t, []string{"id", "user_id"}, [][]string{{"1", "3"}})
+
+	var orders []shardOrder
+	if err := e.Table("order").Where("id", 1).Find(&orders); err == nil {
+		t.Fatal("Find() without AllowScatter() error = nil")
+	}
+
+	if err := e.Table("order").AllowScatter().Where("id", 1).Find(&orders); err != nil || len(orders) != 4 {
+		t.Fatalf("Find() = %v, %v, want 4 rows", orders, err)
+	}
+	for _, c := range shards {
+		executed := c.executed()
+		if len(executed) != 2 || !strings.Contains(executed[0].query, "`order_0") {
+			t.Fatalf("executed %v, want one query per shard", executed)
+		}
+	}
+
+	rows, err := e.Table("order").AllowScatter().Where("user_id", "in", []int{1, 2, 5}).Select()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("Select() = %v, %v, want 2 rows", rows, err)
+	}
+	assertLastQuery(t, shards[1], "select * from `order_02` where (`user_id` in (?,?,?))", int64(1), int64(2), int64(5))
+
+	if _, err := e.Table("order").AllowScatter().Where("id", 1).Update("id", 2); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, shards[1], "update `order_03` set `id`=? where (`id`=?)", int64(2), int64(1))
+	assertNoQuery(t, primary)
+
+	//每个分片单独排序、分页和分组，结果无法合并
+	tests := map[string]func(s *Orm) error{
+		"Limit": func(s *Orm) error { return s.Limit(1).Find(&orders) },
+		"Order": func(s *Orm) error { _, err := s.Order("id", "desc").Select(); return err },
+		"Group": func(s *Orm) error { _, err := s.Group("user_id").Select(); return err },
+		"Having": func(s *Orm) error {
+			_, err := s.Group("user_id").Having("user_id", ">", 1).Select()
+			return err
+		},
+		"FindOne": func(s *Orm) error { return s.FindOne(&shardOrder{}) },
+		"Max":     func(s *Orm) error { _, err := s.Max("id"); return err },
+		"Delete":  func(s *Orm) error { _, err := s.Limit(1).Delete(); return err },
+	}
+	for name, fn := range tests {
+		before := len(shards[0].executed())
+		if err := fn(e.Table("order").AllowScatter()); err == nil {
+			t.Fatalf("%s across shards error = nil", name)
+		}
+		if len(shards[0].executed()) != before {
+			t.Fatalf("%s executed on shards", name)
+		}
+	}
+}
+
+func TestScatterCount(t *testing.T) {
+	e, _, _ := newShardedOrm(t, []string{"cnt"}, [][]string{{"2"}})
+
+	n, err := e.Table("order").AllowScatter().Count()
+	if err != nil || n != 8 {
+		t.Fatalf("Count() = %d, %v, want 8", n, err)
+	}
+}
+
+func TestInsertSelectSharded(t *testing.T) {
+	e, primary, shards := newShardedOrm(t, []string{"id", "user_id"}, nil)
+
+	//查询的分片在其他库
+	query := e.Session().Table("order").Where("user_id", 3)
+	if _, err := e.Table("order_bak").InsertSelect(nil, query); err == nil {
+		t.Fatal("InsertSelect() from another database error = nil")
+	}
+
+	//分片在主库时改写查询的表名
+	e.Sharding.Register("log", ShardingRule{Column: "user_id", Shards: SplitShards("log", 2), Algorithm: HashMod(2)})
+	query = e.Session().Table("log").Where("user_id", 3)
+	if _, err := e.Table("log_bak").InsertSelect([]string{"id"}, query.Field("id")); err != nil {
+		t.Fatalf("InsertSelect() error = %v", err)
+	}
+	assertLastQuery(t, primary, "insert into `log_bak` (`id`) select `id` from `log_01` where (`user_id`=?)", int64(3))
+	if query.TableName != "log" {
+		t.Fatalf("query.TableName = %s, want log", query.TableName)
+	}
+
+	query = e.Session().Table("log").AllowScatter().Where("id", 1)
+	if _, err := e.Table("log_bak").InsertSelect(nil, query); err == nil {
+		t.Fatal("InsertSelect() across shards error = nil")
+	}
+	assertNoQuery(t, shards[0])
+	assertNoQuery(t, shards[1])
+}
@@ -0,0 +1,143 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 设置模型结构体，用于识别软删除等tag选项和调用删除钩子，Find/FindOne及按主键操作时自动设置
+func (e *Orm) Model(model interface{}) *Orm {
+	if v := indirectValue(reflect.ValueOf(model)); v.Kind() == reflect.Struct {
+		e.ModelValue = v
+	}
+
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		if err := checkSoftDelete(t); err != nil {
+			return e.addError(err)
+		}
+		e.ModelType = t
+	}
+	return e
+}
+
+// 忽略软删除：查询包含已删除的记录，Delete()物理删除
+func (e *Orm) Unscoped() *Orm {
+	e.IsUnscoped = true
+	return e
+}
+
+// 按表注册软删除模型，未调用Model()时该表的Delete()也改为更新删除时间，查询自动排除已删除记录
+// 如SetSoftDelete("user", User{})，model没有soft_delete字段时不生效
+func (e *Orm) SetSoftDelete(table string, model interface{}) *Orm {
+	t := reflect.TypeOf(model)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return e
+	}
+	if err := checkSoftDelete(t); err != nil {
+		return e.addError(err)
+	}
+	if e.SoftDeleteTables == nil {
+		e.SoftDeleteTables = make(map[string]reflect.Type)
+	}
+	e.SoftDeleteTables[table] = t
+	return e
+}
+
+// 恢复软删除的记录，只更新已删除的记录
+func (e *Orm) Restore() (int64, error) {
+	column := e.softDeleteColumn()
+	if column == "" {
+		return 0, e.setErrorInfo(errors.New("模型未定义软删除字段，请在tag中标记，如`sql:\"deleted_at,soft_delete\"`"))
+	}
+	e.SoftDeleteCond = "is not null"
+	return e.Update(column, nil)
+}
+
+// 软删除，按字段类型将删除时间字段设置为当前时间，已删除的记录不重复更新
+func (e *Orm) softDelete() (int64, error) {
+	field, fieldType := e.softDeleteField()
+	e.SoftDeleteCond = "is null"
+	return e.Update(field.Column, timeValue(fieldType, e.now()).Interface())
+}
+
+// 软删除字段需可为NULL，支持*time.Time、*int64等整数指针和*string
+func checkSoftDelete(t reflect.Type) error {
+	field := parseSchema(t).SoftDelete
+	if field == nil {
+		return nil
+	}
+	fieldType := t.Field(field.Index).Type
+	if fieldType.Kind() == reflect.Ptr {
+		switch elem := fieldType.Elem(); elem.Kind() {
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.String:
+			return nil
+		case reflect.Struct:
+			if elem == reflect.TypeOf(time.Time{}) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("软删除字段%s的类型%s不能为NULL，请使用*time.Time、*int64或*string", field.Name, fieldType)
+}
+
+// 获取软删除字段及其类型，优先使用Model()设置的模型，其次为SetSoftDelete()注册的模型，未定义时返回nil
+func (e *Orm) softDeleteField() (*schemaField, reflect.Type) {
+	for _, t := range []reflect.Type{e.ModelType, e.SoftDeleteTables[e.logicalTable()]} {
+		if t == nil {
+			continue
+		}
+		if field := parseSchema(t).SoftDelete; field != nil {
+			return field, t.Field(field.Index).Type
+		}
+	}
+	return nil, nil
+}
+
+// 获取软删除字段名，未定义时返回空
+func (e *Orm) softDeleteColumn() string {
+	if field, _ := e.softDeleteField(); field != nil {
+		return field.Column
+	}
+	return ""
+}
+
+// 拼接where条件，scoped为true时自动排除软删除的记录，软删除和恢复时加上删除时间条件，多租户表加上租户条件，返回条件和对应的参数
+func (e *Orm) buildWhere(scoped bool) (string, []interface{}) {
+	where := e.WhereParam + e.OrWhereParam
+	args := e.WhereExec
+
+	cond := e.SoftDeleteCond
+	if cond == "" && scoped && !e.IsUnscoped {
+		cond = "is null"
+	}
+	if column := e.softDeleteColumn(); cond != "" && column != "" {
+		if where != "" {
+			where = "(" + where + ") and "
+		}
+		where += e.getDialect().Quote(column) + " " + cond
+	}
+
+	//租户条件放在最前面，参数在where参数之前
+	if column, tenant, ok := e.tenantScope(); ok {
+		condition := e.getDialect().Quote(column) + "=?"
+		if where != "" {
+			condition += " and (" + where + ")"
+		}
+		where = condition
+		args = append([]interface{}{tenant}, args...)
+	}
+
+	if where == "" {
+		return "", args
+	}
+	return " where " + where, args
+}
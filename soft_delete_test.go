@@ -0,0 +1,124 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type softTimeUser struct {
+	Id        int64      `sql:"id,auto_increment"`
+	DeletedAt *time.Time `sql:"deleted_at,soft_delete"`
+}
+
+type softIntUser struct {
+	Id        int64  `sql:"id,auto_increment"`
+	DeletedAt *int64 `sql:"deleted_at,soft_delete"`
+}
+
+type softUintUser struct {
+	Id        int64   `sql:"id,auto_increment"`
+	DeletedAt *uint32 `sql:"deleted_at,soft_delete"`
+}
+
+type softStringUser struct {
+	Id        int64   `sql:"id,auto_increment"`
+	DeletedAt *string `sql:"deleted_at,soft_delete"`
+}
+
+type softValueUser struct {
+	Id        int64     `sql:"id,auto_increment"`
+	DeletedAt time.Time `sql:"deleted_at,soft_delete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		model   interface{}
+		deleted string      //查询返回的删除时间
+		want    interface{} //删除时绑定的值
+	}{
+		{"time pointer", softTimeUser{}, "2024-01-02 03:04:05", now},
+		{"int pointer", softIntUser{}, "1704164645", now.Unix()},
+		{"uint pointer", softUintUser{}, "1704164645", now.Unix()},
+		{"string pointer", softStringUser{}, "2024-01-02 03:04:05", "2024-01-02 03:04:05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeConnector{failAt: -1, affected: 1}
+			e := newFakeOrm(t, c)
+			e.NowFunc = func() time.Time { return now }
+
+			//只更新未删除的记录
+			if n, err := e.Table("user").Model(tt.model).Where("id", 1).Delete(); err != nil || n != 1 {
+				t.Fatalf("Delete() = %d, %v", n, err)
+			}
+			assertLastQuery(t, c, "update `user` set `deleted_at`=? where ((`id`=?) ) and `deleted_at` is null", tt.want, int64(1))
+
+			//只恢复已删除的记录
+			if _, err := e.Table("user").Model(tt.model).Where("id", 1).Restore(); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			assertLastQuery(t, c, "update `user` set `deleted_at`=? where ((`id`=?) ) and `deleted_at` is not null", nil, int64(1))
+
+			c.columns, c.data = []string{"cnt"}, [][]string{{"1"}}
+			if _, err := e.Table("user").Model(tt.model).Count(); err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			assertLastQuery(t, c, "select count(*) as cnt from `user` where `deleted_at` is null")
+
+			//NULL为nil，有值时解析到指针指向的值
+			c.columns, c.data = []string{"id", "deleted_at"}, [][]string{{"1", fakeNull}, {"2", tt.deleted}}
+			users := reflect.New(reflect.SliceOf(reflect.TypeOf(tt.model)))
+			if err := e.Table("user").Unscoped().Find(users.Interface()); err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			assertLastQuery(t, c, "select * from `user`")
+			if got := users.Elem().Index(0).Field(1); !got.IsNil() {
+				t.Fatalf("DeletedAt = %v, want nil", got.Elem())
+			}
+			got := users.Elem().Index(1).Field(1)
+			if got.IsNil() {
+				t.Fatal("DeletedAt = nil, want deleted time")
+			}
+			if want := timeValue(got.Type(), now).Elem().Interface(); !reflect.DeepEqual(got.Elem().Interface(), want) {
+				t.Fatalf("DeletedAt = %v, want %v", got.Elem(), want)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteRegisteredTable(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetSoftDelete("user", softIntUser{})
+	e.NowFunc = func() time.Time { return time.Unix(100, 0) }
+
+	//未设置模型时按注册的模型软删除
+	if _, err := e.Table("user").Where("id", 1).Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `deleted_at`=? where ((`id`=?) ) and `deleted_at` is null", int64(100), int64(1))
+
+	if _, err := e.Table("user").Unscoped().Where("id", 1).Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	assertLastQuery(t, c, "delete from `user` where (`id`=?)", int64(1))
+}
+
+func TestSoftDeleteNotNullable(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	if _, err := e.Table("user").Model(softValueUser{}).Where("id", 1).Delete(); err == nil {
+		t.Fatal("Delete() with time.Time soft delete field error = nil")
+	}
+	var users []softValueUser
+	if err := e.Table("user").Find(&users); err == nil {
+		t.Fatal("Find() with time.Time soft delete field error = nil")
+	}
+	if e.SetSoftDelete("user", softValueUser{}); e.Err == nil {
+		t.Fatal("SetSoftDelete() with time.Time soft delete field error = nil")
+	}
+	assertNoQuery(t, c)
+}
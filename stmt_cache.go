@@ -0,0 +1,161 @@
+package orm
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// 默认缓存的预处理语句个数
+const defaultStmtCacheSize = 100
+
+// 预处理语句的LRU缓存，按连接池和sql缓存，淘汰时关闭语句，Session()创建的对象共享同一个缓存
+type StmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[stmtKey]*list.Element
+}
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+type stmtEntry struct {
+	key     stmtKey
+	stmt    *sql.Stmt
+	refs    int  //正在使用的个数
+	evicted bool //已淘汰，使用完后关闭
+}
+
+// 新建缓存，capacity为缓存的语句个数
+func NewStmtCache(capacity int) *StmtCache {
+	return &StmtCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[stmtKey]*list.Element),
+	}
+}
+
+// 设置预处理语句缓存，size<=0时不缓存，每次执行后关闭语句
+func (e *Orm) SetStmtCache(size int) *Orm {
+	if e.StmtCache != nil {
+		e.StmtCache.Close()
+	}
+	e.StmtCache = nil
+	if size > 0 {
+		e.StmtCache = NewStmtCache(size)
+	}
+	return e
+}
+
+// 获取预处理语句，不存在时在db上预处理并缓存，使用完后调用release
+func (c *StmtCache) get(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	key := stmtKey{db: db, query: query}
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.mu.Unlock()
+
+	//预处理不持有锁，并发预处理同一条sql时只保留一个
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		stmt.Close()
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	entry := &stmtEntry{key: key, stmt: stmt, refs: 1}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.ll.Len() > c.capacity {
+		c.evict(c.ll.Back())
+	}
+	return entry, nil
+}
+
+// 使用完毕，已淘汰且无人使用时关闭
+func (c *StmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// 移出缓存，无人使用时立即关闭，否则由最后一个使用者关闭
+func (c *StmtCache) evict(el *list.Element) {
+	entry := c.ll.Remove(el).(*stmtEntry)
+	delete(c.items, entry.key)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// 缓存的语句个数
+func (c *StmtCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// 关闭并清空所有缓存的语句
+func (c *StmtCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.ll.Len() > 0 {
+		c.evict(c.ll.Back())
+	}
+}
+
+// 预处理并执行，有缓存时复用语句，事务中通过tx.Stmt绑定到事务连接
+func (e *Orm) execPrepared(query string, args []interface{}) (sql.Result, error) {
+	//不缓存，预处理后立即关闭
+	if e.StmtCache == nil {
+		stmt, err := e.getExecutor().PrepareContext(e.context(), query)
+		if err != nil {
+			return nil, err
+		}
+		defer stmt.Close()
+		return stmt.ExecContext(e.context(), args...)
+	}
+
+	entry, err := e.StmtCache.get(e.context(), e.getDb(), query)
+	if err != nil {
+		return nil, err
+	}
+	defer e.StmtCache.release(entry)
+
+	stmt := entry.stmt
+	if e.TransStatus == 1 {
+		//事务结束时自动关闭，不影响缓存的语句
+		stmt = e.Tx.StmtContext(e.context(), stmt)
+		defer stmt.Close()
+	}
+	return stmt.ExecContext(e.context(), args...)
+}
+
+// 关闭缓存的预处理语句和连接池
+func (e *Orm) Close() error {
+	if e.StmtCache != nil {
+		e.StmtCache.Close()
+	}
+	return e.Db.Close()
+}
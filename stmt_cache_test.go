@@ -0,0 +1,205 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+)
+
+// 预处理和关闭的语句个数
+func assertStmts(t *testing.T, c *fakeConnector, wantPrepared int, wantClosed int) {
+	t.Helper()
+	if prepared, closed := c.stmtCount(); prepared != wantPrepared || closed != wantClosed {
+		t.Fatalf("prepared, closed = %d, %d, want %d, %d", prepared, closed, wantPrepared, wantClosed)
+	}
+}
+
+func TestStmtCacheReuse(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetStmtCache(10)
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.Table("user").Where("uid", i).Update("name", "a"); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	assertStmts(t, c, 1, 0)
+	if n := e.StmtCache.Len(); n != 1 {
+		t.Fatalf("Len() = %d, want 1", n)
+	}
+}
+
+func TestStmtCacheEvict(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetStmtCache(2)
+
+	for _, column := range []string{"a", "b", "c"} {
+		if _, err := e.Table("user").Where("uid", 1).Update(column, 1); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	assertStmts(t, c, 3, 1)
+	if n := e.StmtCache.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+
+	//被淘汰的语句重新预处理
+	if _, err := e.Table("user").Where("uid", 1).Update("a", 1); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertStmts(t, c, 4, 2)
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	cache := NewStmtCache(1)
+	ctx := context.Background()
+
+	first, err := cache.get(ctx, db, "select 1")
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	again, err := cache.get(ctx, db, "select 1")
+	if err != nil || again != first {
+		t.Fatalf("get() = %p, %v, want %p", again, err, first)
+	}
+
+	//淘汰正在使用的语句，最后一个使用者释放后才关闭
+	second, err := cache.get(ctx, db, "select 2")
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	assertStmts(t, c, 2, 0)
+	if _, err := first.stmt.Exec(); err != nil {
+		t.Fatalf("Exec() on evicted statement error = %v", err)
+	}
+
+	cache.release(first)
+	assertStmts(t, c, 2, 0)
+	cache.release(again)
+	assertStmts(t, c, 2, 1)
+
+	//关闭缓存时正在使用的语句同样延迟到释放后关闭
+	cache.Close()
+	assertStmts(t, c, 2, 1)
+	cache.release(second)
+	assertStmts(t, c, 2, 2)
+	if n := cache.Len(); n != 0 {
+		t.Fatalf("Len() = %d, want 0", n)
+	}
+}
+
+func TestStmtCacheConcurrentPrepare(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	cache := NewStmtCache(10)
+
+	//并发预处理同一条sql，只缓存一个，其余的立即关闭
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry, err := cache.get(context.Background(), db, "select 1")
+			if err != nil {
+				t.Errorf("get() error = %v", err)
+				return
+			}
+			if _, err := entry.stmt.Exec(); err != nil {
+				t.Errorf("Exec() error = %v", err)
+			}
+			cache.release(entry)
+		}()
+	}
+	wg.Wait()
+
+	if n := cache.Len(); n != 1 {
+		t.Fatalf("Len() = %d, want 1", n)
+	}
+	if prepared, closed := c.stmtCount(); prepared-closed != 1 {
+		t.Fatalf("prepared, closed = %d, %d, want exactly one open statement", prepared, closed)
+	}
+
+	cache.Close()
+	if prepared, closed := c.stmtCount(); prepared != closed {
+		t.Fatalf("prepared, closed = %d, %d, want all closed", prepared, closed)
+	}
+}
+
+func TestStmtCacheDisabled(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetStmtCache(10)
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertStmts(t, c, 1, 0)
+
+	//关闭已缓存的语句，之后每次执行后关闭
+	e.SetStmtCache(0)
+	assertStmts(t, c, 1, 1)
+	if e.StmtCache != nil {
+		t.Fatal("StmtCache != nil after SetStmtCache(0)")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	assertStmts(t, c, 3, 3)
+}
+
+func TestStmtCacheInTransaction(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetStmtCache(10)
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := e.Begin(); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	if err := e.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	//事务中绑定的语句关闭时不关闭缓存的语句
+	assertStmts(t, c, 1, 0)
+	if n := e.StmtCache.Len(); n != 1 {
+		t.Fatalf("Len() = %d, want 1", n)
+	}
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "a"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertStmts(t, c, 1, 0)
+	if n := len(c.executed()); n != 4 {
+		t.Fatalf("executed %d statements, want 4", n)
+	}
+}
+
+func TestOrmClose(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c).SetStmtCache(10)
+
+	for _, column := range []string{"a", "b"} {
+		if _, err := e.Table("user").Where("uid", 1).Update(column, 1); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	assertStmts(t, c, 2, 2)
+	if n := e.StmtCache.Len(); n != 0 {
+		t.Fatalf("Len() = %d, want 0", n)
+	}
+}
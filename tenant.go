@@ -0,0 +1,97 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+type tenantKey struct{}
+
+// 将租户ID附加到上下文
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// 获取上下文中的租户ID
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// 返回使用ctx的会话，执行语句时传入ctx，多租户表从ctx中读取租户ID
+func (e *Orm) WithContext(ctx context.Context) *Orm {
+	s := e.Session()
+	s.Ctx = ctx
+	return s
+}
+
+// 多租户：tables中的表查询、更新、删除时自动加上column=租户ID的条件，插入时自动填充column
+func (e *Orm) SetTenant(column string, tables ...string) *Orm {
+	e.TenantColumn = column
+	e.TenantTables = make(map[string]bool, len(tables))
+	for _, table := range tables {
+		e.TenantTables[table] = true
+	}
+	return e
+}
+
+// 本次操作不按租户隔离，用于管理后台和跨租户的任务
+func (e *Orm) SkipTenant() *Orm {
+	e.IsSkipTenant = true
+	return e
+}
+
+// 执行语句使用的上下文
+func (e *Orm) context() context.Context {
+	if e.Ctx == nil {
+		return context.Background()
+	}
+	return e.Ctx
+}
+
+// 当前表的租户字段和租户ID，不需要隔离时scoped为false
+// 上下文中没有租户ID时tenant为nil，条件为column=NULL，不会匹配任何记录
+func (e *Orm) tenantScope() (column string, tenant interface{}, scoped bool) {
+	if e.TenantColumn == "" || e.IsSkipTenant || !e.TenantTables[e.logicalTable()] {
+		return "", nil, false
+	}
+	tenant, _ = TenantFromContext(e.Ctx)
+	return e.TenantColumn, tenant, true
+}
+
+// 多租户表必须在上下文中设置租户ID，或调用SkipTenant()
+func (e *Orm) checkTenant() error {
+	if _, tenant, scoped := e.tenantScope(); scoped && tenant == nil {
+		return e.setErrorInfo(errors.New("表" + e.logicalTable() + "需要租户ID，请使用WithContext(orm.WithTenant(ctx, id))或调用SkipTenant()"))
+	}
+	return nil
+}
+
+// 插入时填充租户字段，已设置为其他租户时返回错误
+func (e *Orm) tenantValue(item reflect.Value, field *schemaField, tenant interface{}) (interface{}, error) {
+	value := item.Field(field.Index)
+	if !value.IsZero() && fmt.Sprint(value.Interface()) != fmt.Sprint(tenant) {
+		return nil, e.setErrorInfo(errors.New("不能插入其他租户的数据"))
+	}
+	if tv := reflect.ValueOf(tenant); value.CanSet() {
+		if tv.Type().AssignableTo(value.Type()) {
+			value.Set(tv)
+		} else if (tv.CanInt() || tv.CanUint()) && (value.CanInt() || value.CanUint()) {
+			value.Set(tv.Convert(value.Type()))
+		}
+	}
+	return tenant, nil
+}
+
+// 分片前的逻辑表名
+func (e *Orm) logicalTable() string {
+	if e.LogicalTable != "" {
+		return e.LogicalTable
+	}
+	return e.TableName
+}
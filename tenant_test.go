@@ -0,0 +1,218 @@
+package orm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type tenantOrder struct {
+	Id       int64 `sql:"id,auto_increment"`
+	TenantId int64 `sql:"tenant_id"`
+	Amount   int64 `sql:"amount"`
+}
+
+type tenantLog struct {
+	A         int64  `sql:"a"`
+	B         int64  `sql:"b"`
+	C         string `sql:"c"`
+	D         string `sql:"d"`
+	DeletedAt *int64 `sql:"deleted_at,soft_delete"`
+}
+
+type tenantEvent struct {
+	A int64 `sql:"a"`
+	B int64 `sql:"b"`
+	C int64 `sql:"c"`
+	D int64 `sql:"d"`
+}
+
+// order、log按tenant_id隔离，返回当前租户为7的会话
+func newTenantOrm(t *testing.T, c *fakeConnector) (e *Orm, db *Orm) {
+	t.Helper()
+	e = newFakeOrm(t, c).SetTenant("tenant_id", "order", "log")
+	return e, e.WithContext(WithTenant(context.Background(), int64(7)))
+}
+
+func TestTenantScope(t *testing.T) {
+	c := &fakeConnector{columns: []string{"id", "tenant_id", "amount"}, data: [][]string{{"1", "7", "5"}}, failAt: -1}
+	_, db := newTenantOrm(t, c)
+
+	//租户条件在最前面，参数在where参数之前
+	if _, err := db.Table("order").Where("amount", ">", 1).OrWhere("amount", 0).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `order` where `tenant_id`=? and ((`amount` > ?)  or (`amount`=?) )", int64(7), int64(1), int64(0))
+
+	var orders []tenantOrder
+	if err := db.Table("order").Where("id", 1).Group("amount").Having("amount", ">", 2).Find(&orders); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `order` where `tenant_id`=? and ((`id`=?) ) group by `amount` having (`amount` > ?)", int64(7), int64(1), int64(2))
+
+	if _, err := db.Table("order").Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `order` where `tenant_id`=?", int64(7))
+
+	if _, err := db.Table("order").Where("id", 1).Update(tenantOrder{TenantId: 8, Amount: 3}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `order` set `amount`=? where `tenant_id`=? and ((`id`=?) )", int64(3), int64(7), int64(1))
+
+	if _, err := db.Table("order").Where("id", 1).Update("tenant_id", 8); err == nil {
+		t.Fatal("Update() of tenant column error = nil")
+	}
+
+	if _, err := db.Table("order").Where("id", 1).Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	assertLastQuery(t, c, "delete from `order` where `tenant_id`=? and ((`id`=?) )", int64(7), int64(1))
+
+	//软删除条件在租户条件之内
+	if _, err := db.Table("log").Model(tenantLog{}).Where("a", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `log` where `tenant_id`=? and (((`a`=?) ) and `deleted_at` is null)", int64(7), int64(1))
+
+	//未隔离的表不加条件
+	if _, err := db.Table("user").Where("uid", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `user` where (`uid`=?)", int64(1))
+
+	if _, err := db.Table("order").SkipTenant().Where("id", 1).Select(); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	assertLastQuery(t, c, "select * from `order` where (`id`=?)", int64(1))
+}
+
+func TestTenantCount(t *testing.T) {
+	c := &fakeConnector{columns: []string{"cnt"}, data: [][]string{{"3"}}, failAt: -1}
+	_, db := newTenantOrm(t, c)
+
+	if n, err := db.Table("order").Where("amount", 1).Count(); err != nil || n != 3 {
+		t.Fatalf("Count() = %d, %v", n, err)
+	}
+	assertLastQuery(t, c, "select count(*) as cnt from `order` where `tenant_id`=? and ((`amount`=?) )", int64(7), int64(1))
+}
+
+func TestTenantRequired(t *testing.T) {
+	c := &fakeConnector{columns: []string{"cnt"}, data: [][]string{{"3"}}, failAt: -1}
+	e, _ := newTenantOrm(t, c)
+
+	var orders []tenantOrder
+	calls := map[string]func() error{
+		"Select": func() error { _, err := e.Table("order").Select(); return err },
+		"Find":   func() error { return e.Table("order").Find(&orders) },
+		"Count":  func() error { _, err := e.Table("order").Count(); return err },
+		"Update": func() error { _, err := e.Table("order").Where("id", 1).Update("amount", 1); return err },
+		"Delete": func() error { _, err := e.Table("order").Where("id", 1).Delete(); return err },
+		"Insert": func() error { _, err := e.Table("order").Insert(&tenantOrder{}); return err },
+		"InsertBatch": func() error {
+			_, err := e.Table("order").InsertBatch([]tenantOrder{{}}, 10)
+			return err
+		},
+		"Scan": func() error { var n int64; return e.Table("order").Field("amount").Scan(&n) },
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Fatalf("%s() without tenant error = nil", name)
+		}
+	}
+	assertNoQuery(t, c)
+
+	if _, err := e.Table("order").SkipTenant().Count(); err != nil {
+		t.Fatalf("Count() with SkipTenant() error = %v", err)
+	}
+}
+
+func TestTenantInsert(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	_, db := newTenantOrm(t, c)
+
+	//填充租户字段并回写
+	order := tenantOrder{Amount: 5}
+	if _, err := db.Table("order").Insert(&order); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `order` (`tenant_id`,`amount`) values (?,?)", int64(7), int64(5))
+	if order.TenantId != 7 {
+		t.Fatalf("TenantId = %d, want 7", order.TenantId)
+	}
+
+	if _, err := db.Table("order").Insert(&tenantOrder{TenantId: 8}); err == nil {
+		t.Fatal("Insert() for another tenant error = nil")
+	}
+
+	//结构体中没有租户字段时追加，跳过软删除字段
+	if _, err := db.Table("log").Insert([]tenantLog{{A: 1}, {A: 2}}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `log` (`a`,`b`,`c`,`d`,`tenant_id`) values (?,?,?,?,?),(?,?,?,?,?)",
+		int64(1), int64(0), "", "", int64(7), int64(2), int64(0), "", "", int64(7))
+}
+
+func TestTenantInsertBatchPlaceholders(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	_, db := newTenantOrm(t, c)
+
+	//与insertItems的字段一致：跳过自增和软删除字段，追加租户字段
+	if n := db.Table("log").insertColumnNum(reflect.ValueOf(tenantLog{})); n != 5 {
+		t.Fatalf("insertColumnNum(log) = %d, want 5", n)
+	}
+	if n := db.Table("log").SkipTenant().insertColumnNum(reflect.ValueOf(tenantLog{})); n != 4 {
+		t.Fatalf("insertColumnNum(log) with SkipTenant() = %d, want 4", n)
+	}
+	if n := db.Table("order").insertColumnNum(reflect.ValueOf(tenantOrder{})); n != 2 {
+		t.Fatalf("insertColumnNum(order) = %d, want 2", n)
+	}
+
+	//每行4个字段加租户字段，共5个占位符
+	events := make([]tenantEvent, maxPlaceholders/5+1)
+	if _, err := db.Table("log").InsertBatch(events, len(events)); err != nil {
+		t.Fatalf("InsertBatch() error = %v", err)
+	}
+	executed := c.executed()
+	if len(executed) != 2 {
+		t.Fatalf("executed %d statements, want 2", len(executed))
+	}
+	for _, q := range executed {
+		if len(q.args) > maxPlaceholders {
+			t.Fatalf("statement has %d placeholders, want at most %d", len(q.args), maxPlaceholders)
+		}
+	}
+}
+
+func TestTenantInsertSelect(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	_, db := newTenantOrm(t, c)
+
+	//租户ID作为查询的最后一个字段，参数在where参数之前
+	query := db.Session().Table("order_import").Field("amount").Where("batch", 3)
+	if _, err := db.Table("order").InsertSelect([]string{"amount"}, query); err != nil {
+		t.Fatalf("InsertSelect() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `order` (`amount`,`tenant_id`) select `amount`,? from `order_import` where (`batch`=?)", int64(7), int64(3))
+	if query.FieldParam != "`amount`" {
+		t.Fatalf("query.FieldParam = %s, want `amount`", query.FieldParam)
+	}
+
+	//查询的表也按租户隔离
+	query = db.Session().Table("log").Field("a").Where("b", 1)
+	if _, err := db.Table("order").InsertSelect([]string{"amount"}, query); err != nil {
+		t.Fatalf("InsertSelect() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `order` (`amount`,`tenant_id`) select `a`,? from `log` where `tenant_id`=? and ((`b`=?) )", int64(7), int64(7), int64(1))
+
+	before := len(c.executed())
+	if _, err := db.Table("order").InsertSelect(nil, db.Session().Table("order_import")); err == nil {
+		t.Fatal("InsertSelect() without columns error = nil")
+	}
+	if _, err := db.Table("order").InsertSelect([]string{"amount", "tenant_id"}, db.Session().Table("order_import").Field("amount,tenant_id")); err == nil {
+		t.Fatal("InsertSelect() of tenant column error = nil")
+	}
+	if len(c.executed()) != before {
+		t.Fatal("rejected InsertSelect() executed")
+	}
+}
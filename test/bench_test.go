@@ -1,13 +1,15 @@
-package orm
+package test
 
 import (
 	"testing"
 
+	"github.com/dingqing/orm"
+	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 func BenchmarkOrmSelect(b *testing.B) {
-	e, _ := NewMysql("root", "123456", "127.0.0.1:3306", "test")
+	e, _ := orm.NewMysql("root", "123456", "127.0.0.1:3306", "test")
 
 	type User struct {
 		Username   string `gorm:"username"`
@@ -44,7 +46,7 @@ func BenchmarkGormSelect(b *testing.B) {
 }
 
 func BenchmarkOrmUpdate(b *testing.B) {
-	e, _ := NewMysql("root", "123456", "127.0.0.1:3306", "test")
+	e, _ := orm.NewMysql("root", "123456", "127.0.0.1:3306", "test")
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
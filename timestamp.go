@@ -0,0 +1,61 @@
+package orm
+
+import (
+	"reflect"
+	"time"
+)
+
+// 时间字符串格式
+const timeFormat = "2006-01-02 15:04:05"
+
+// 当前时间，设置NowFunc后使用自定义时钟，便于测试
+func (e *Orm) now() time.Time {
+	if e.NowFunc != nil {
+		return e.NowFunc()
+	}
+	return time.Now()
+}
+
+// 按字段类型生成时间值，支持time.Time、字符串、整数（秒级时间戳）及其指针
+func timeValue(t reflect.Type, now time.Time) reflect.Value {
+	switch t.Kind() {
+	case reflect.Ptr:
+		if value := timeValue(t.Elem(), now); value.Type().AssignableTo(t.Elem()) {
+			ptr := reflect.New(t.Elem())
+			ptr.Elem().Set(value)
+			return ptr
+		}
+	case reflect.String:
+		return reflect.ValueOf(now.Format(timeFormat)).Convert(t)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(now.Unix()).Convert(t)
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(uint64(now.Unix())).Convert(t)
+	}
+
+	//time.Time及其他类型直接使用time.Time
+	return reflect.ValueOf(now)
+}
+
+// 填充时间字段，结构体可寻址时同时回写，返回用于绑定的值
+func fillTime(item reflect.Value, field *schemaField, now time.Time) interface{} {
+	fieldValue := item.Field(field.Index)
+	value := timeValue(fieldValue.Type(), now)
+	if fieldValue.CanSet() && value.Type().AssignableTo(fieldValue.Type()) {
+		fieldValue.Set(value)
+	}
+	return value.Interface()
+}
+
+// 更新时追加更新时间字段，已手动设置该字段时跳过
+func (e *Orm) appendUpdateTime(column string) {
+	if e.ModelType == nil {
+		return
+	}
+	field := parseSchema(e.ModelType).AutoUpdateTime
+	if field == nil || field.Column == column {
+		return
+	}
+	e.UpdateParam += "," + e.getDialect().Quote(field.Column) + "=?"
+	e.UpdateExec = append(e.UpdateExec, timeValue(e.ModelType.Field(field.Index).Type, e.now()).Interface())
+}
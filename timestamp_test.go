@@ -0,0 +1,107 @@
+package orm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type stampUser struct {
+	Uid       int64     `sql:"uid,auto_increment"`
+	Name      string    `sql:"name"`
+	CreatedAt time.Time `sql:"created_at,autoCreateTime"`
+	UpdatedAt int64     `sql:"updated_at,autoUpdateTime"`
+}
+
+func newStampOrm(t *testing.T, c *fakeConnector, now time.Time) *Orm {
+	t.Helper()
+	e := newFakeOrm(t, c)
+	e.NowFunc = func() time.Time { return now }
+	return e
+}
+
+func TestTimeValue(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	unix := now.Unix()
+	text := "2024-01-02 03:04:05"
+	tests := []struct {
+		name string
+		t    reflect.Type
+		want interface{}
+	}{
+		{"time", reflect.TypeOf(time.Time{}), now},
+		{"time pointer", reflect.TypeOf(&now), &now},
+		{"string", reflect.TypeOf(""), text},
+		{"string pointer", reflect.TypeOf(&text), &text},
+		{"int64", reflect.TypeOf(int64(0)), unix},
+		{"int32", reflect.TypeOf(int32(0)), int32(unix)},
+		{"int pointer", reflect.TypeOf(&unix), &unix},
+		{"uint64", reflect.TypeOf(uint64(0)), uint64(unix)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeValue(tt.t, now).Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("timeValue(%s) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertTimestamps(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := &fakeConnector{failAt: -1}
+	e := newStampOrm(t, c, now)
+
+	//为空时填充并回写
+	user := stampUser{Name: "a"}
+	if _, err := e.Table("user").Insert(&user); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`,`created_at`,`updated_at`) values (?,?,?)", "a", now, now.Unix())
+	if !user.CreatedAt.Equal(now) || user.UpdatedAt != now.Unix() {
+		t.Fatalf("user = %+v, want timestamps filled", user)
+	}
+
+	//已设置的值不覆盖
+	created := now.Add(-time.Hour)
+	if _, err := e.Table("user").Replace(&stampUser{Name: "b", CreatedAt: created, UpdatedAt: 1}); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	assertLastQuery(t, c, "replace into `user` (`name`,`created_at`,`updated_at`) values (?,?,?)", "b", created, int64(1))
+
+	//批量插入使用同一时间
+	if _, err := e.Table("user").InsertBatch([]stampUser{{Name: "c"}, {Name: "d"}}, 10); err != nil {
+		t.Fatalf("InsertBatch() error = %v", err)
+	}
+	assertLastQuery(t, c, "insert into `user` (`name`,`created_at`,`updated_at`) values (?,?,?),(?,?,?)",
+		"c", now, now.Unix(), "d", now, now.Unix())
+}
+
+func TestUpdateTimestamps(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := &fakeConnector{failAt: -1}
+	e := newStampOrm(t, c, now)
+
+	//结构体更新时填充更新时间，不更新创建时间
+	if _, err := e.Table("user").Where("uid", 1).Update(stampUser{Name: "a", UpdatedAt: 1}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=?,`updated_at`=? where (`uid`=?)", "a", now.Unix(), int64(1))
+
+	//设置模型时单个字段更新追加更新时间
+	if _, err := e.Table("user").Model(stampUser{}).Where("uid", 1).Update("name", "b"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=?,`updated_at`=? where (`uid`=?)", "b", now.Unix(), int64(1))
+
+	//手动设置更新时间时不重复追加
+	if _, err := e.Table("user").Model(stampUser{}).Where("uid", 1).Update("updated_at", 5); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `updated_at`=? where (`uid`=?)", int64(5), int64(1))
+
+	if _, err := e.Table("user").Where("uid", 1).Update("name", "c"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=? where (`uid`=?)", "c", int64(1))
+}
@@ -0,0 +1,26 @@
+package orm
+
+import "reflect"
+
+// 乐观锁：以更新前的版本号作为条件，与已有条件为and关系
+func (e *Orm) whereVersion(column string, value interface{}) {
+	if e.WhereParam != "" {
+		e.WhereParam = "(" + e.WhereParam + ") and "
+	}
+	e.WhereParam += "(" + e.getDialect().Quote(column) + "=?) "
+	e.WhereExec = append(e.WhereExec, value)
+}
+
+// 更新成功后将结构体中的版本号加1，结构体不可寻址时跳过
+func increaseVersion(item reflect.Value, field *schemaField) {
+	fieldValue := item.Field(field.Index)
+	if !fieldValue.CanSet() {
+		return
+	}
+	switch fieldValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fieldValue.SetInt(fieldValue.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fieldValue.SetUint(fieldValue.Uint() + 1)
+	}
+}
@@ -0,0 +1,51 @@
+package orm
+
+import (
+	"errors"
+	"testing"
+)
+
+type versionUser struct {
+	Uid     int64  `sql:"uid,auto_increment"`
+	Name    string `sql:"name"`
+	Version int64  `sql:"version,version"`
+}
+
+func TestUpdateVersion(t *testing.T) {
+	c := &fakeConnector{failAt: -1, affected: 1}
+	e := newFakeOrm(t, c)
+
+	//以更新前的版本号作为条件，更新成功后回写版本号
+	user := versionUser{Uid: 1, Name: "a", Version: 3}
+	if n, err := e.Table("user").UpdateByPK(&user); err != nil || n != 1 {
+		t.Fatalf("UpdateByPK() = %d, %v", n, err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=?,`version`=`version`+1 where ((`uid`=?) ) and (`version`=?)", "a", int64(1), int64(3))
+	if user.Version != 4 {
+		t.Fatalf("Version = %d, want 4", user.Version)
+	}
+
+	if _, err := e.Table("user").Where("name", "a").Update(versionUser{Name: "b", Version: 4}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	assertLastQuery(t, c, "update `user` set `name`=?,`version`=`version`+1 where ((`name`=?) ) and (`version`=?)", "b", "a", int64(4))
+}
+
+func TestUpdateStaleVersion(t *testing.T) {
+	c := &fakeConnector{failAt: -1}
+	e := newFakeOrm(t, c)
+
+	//版本号已变化时没有更新到记录
+	user := versionUser{Uid: 1, Name: "a", Version: 3}
+	if _, err := e.Table("user").UpdateByPK(&user); !errors.Is(err, ErrStaleObject) {
+		t.Fatalf("UpdateByPK() error = %v, want %v", err, ErrStaleObject)
+	}
+	if user.Version != 3 {
+		t.Fatalf("Version = %d, want 3", user.Version)
+	}
+
+	//没有版本号字段时不检查影响的行数
+	if n, err := e.Table("user").Where("uid", 1).Update(fakeUser{Name: "a"}); err != nil || n != 0 {
+		t.Fatalf("Update() = %d, %v", n, err)
+	}
+}